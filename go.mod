@@ -4,6 +4,7 @@ go 1.24
 
 require (
 	github.com/ONSdigital/dp-net/v3 v3.2.0
+	github.com/go-logr/logr v1.4.2
 	github.com/hokaccha/go-prettyjson v0.0.0-20211117102719-0474bc63780f
 	github.com/smartystreets/goconvey v1.8.1
 	go.opentelemetry.io/otel v1.35.0
@@ -13,7 +14,6 @@ require (
 require (
 	github.com/ONSdigital/dp-api-clients-go/v2 v2.266.0 // indirect
 	github.com/fatih/color v1.18.0 // indirect
-	github.com/go-logr/logr v1.4.2 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
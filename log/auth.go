@@ -1,8 +1,37 @@
 package log
 
-type eventAuth struct {
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+)
+
+// EventAuth is the data structure used for logging identity information.
+//
+// It can be stored in a context using WithAuth, or returned by an
+// IdentityExtractor passed to Middleware, to include it in every event
+// logged with that context.
+type EventAuth struct {
 	Identity     string       `json:"identity,omitempty"`
 	IdentityType identityType `json:"identity_type,omitempty"`
+
+	// Optional permission data
+	Roles  []string `json:"roles,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+
+	// Optional authentication data
+	Method    authMethod `json:"method,omitempty"`
+	Issuer    string     `json:"issuer,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Actor is the identity acting on behalf of Identity, for example
+	// an administrator impersonating a user, or a service making a
+	// request on behalf of a user
+	Actor *EventAuth `json:"actor,omitempty"`
 }
 
 type identityType string
@@ -14,15 +43,191 @@ const (
 	USER identityType = "user"
 )
 
-func (l *eventAuth) attach(le *EventData) {
+type authMethod string
+
+const (
+	// JWT represents authentication using a JSON web token
+	JWT authMethod = "jwt"
+	// SERVICE_TOKEN represents authentication using a service token
+	//nolint:revive,stylecheck // Named to match the existing SERVICE and USER constants
+	SERVICE_TOKEN authMethod = "service_token"
+)
+
+func (l *EventAuth) attach(le *EventData) {
 	le.Auth = l
 }
 
+// snapshot returns a copy of l which doesn't share any data with it
+func (l *EventAuth) snapshot() *EventAuth {
+	c := *l
+	c.Roles = append([]string(nil), l.Roles...)
+	c.Groups = append([]string(nil), l.Groups...)
+	if l.Actor != nil {
+		c.Actor = l.Actor.snapshot()
+	}
+	return &c
+}
+
 // Auth returns an option you can pass to Event to include identity information,
 // for example the identity type and user/service ID from an inbound HTTP request
 func Auth(identityType identityType, identity string) option {
-	return &eventAuth{
+	return &EventAuth{
 		Identity:     identity,
 		IdentityType: identityType,
 	}
 }
+
+// AuthFromClaims returns an option you can pass to Event to include identity
+// information from a decoded JWT claims map, for example from an AWS Cognito
+// access or ID token.
+//
+// The identity is taken from the email, username or cognito:username claims
+// for a user, or the client_id or azp claims for a service, falling back to
+// the sub claim. Roles and groups are taken from the roles, groups and
+// cognito:groups claims, and an RFC 8693 act claim is included as the Actor.
+//
+// Only these claims are logged, and any value which looks like a raw token
+// (e.g. a JWT or bearer token) is redacted.
+func AuthFromClaims(claims map[string]interface{}) option {
+	return authFromClaims(claims)
+}
+
+// authFromClaims builds the *EventAuth returned by AuthFromClaims
+func authFromClaims(claims map[string]interface{}) *EventAuth {
+	auth := &EventAuth{
+		Method: JWT,
+		Issuer: redactToken(claimString(claims, "iss")),
+		Roles:  claimStrings(claims, "roles", "cognito:roles"),
+		Groups: claimStrings(claims, "groups", "cognito:groups"),
+	}
+
+	if user := claimString(claims, "email", "username", "cognito:username"); user != "" {
+		auth.Identity, auth.IdentityType = user, USER
+	} else if service := claimString(claims, "client_id", "azp"); service != "" {
+		auth.Identity, auth.IdentityType = service, SERVICE
+	} else if sub := claimString(claims, "sub"); sub != "" {
+		auth.Identity, auth.IdentityType = sub, USER
+	}
+	auth.Identity = redactToken(auth.Identity)
+
+	if exp, ok := claimNumber(claims["exp"]); ok {
+		t := time.Unix(int64(exp), 0).UTC()
+		auth.ExpiresAt = &t
+	}
+
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		auth.Actor = authFromClaims(act)
+		auth.Actor.Method = ""
+	}
+
+	return auth
+}
+
+// redactedToken replaces values which look like a raw token
+const redactedToken = "[REDACTED]"
+
+// redactToken returns redactedToken if s looks like a JWT or bearer token,
+// otherwise it returns s
+func redactToken(s string) string {
+	if strings.HasPrefix(strings.ToLower(s), "bearer ") {
+		return redactedToken
+	}
+	if parts := strings.Split(s, "."); len(parts) == 3 && len(s) > 32 && !strings.ContainsAny(s, " @/:") {
+		return redactedToken
+	}
+	return s
+}
+
+// claimString returns the first of the named claims which is a non-empty string
+func claimString(claims map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if s, ok := claims[name].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// claimStrings returns the values of the named claims, which may be a single
+// string, a space separated string (as for OAuth scopes) or a list of strings
+func claimStrings(claims map[string]interface{}, names ...string) []string {
+	var values []string
+	for _, name := range names {
+		switch v := claims[name].(type) {
+		case string:
+			values = append(values, strings.Fields(v)...)
+		case []string:
+			values = append(values, v...)
+		case []interface{}:
+			for _, i := range v {
+				if s, ok := i.(string); ok {
+					values = append(values, s)
+				}
+			}
+		}
+	}
+
+	for i := range values {
+		values[i] = redactToken(values[i])
+	}
+	return values
+}
+
+// claimNumber returns a numeric claim value, which is a float64 when
+// decoded by encoding/json but may be another numeric type
+func claimNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// WithAuth returns a copy of ctx which includes the identity information,
+// so every event logged with the returned context includes it.
+//
+// An Auth option passed to Event takes precedence over the context.
+func WithAuth(ctx context.Context, auth *EventAuth) context.Context {
+	return context.WithValue(ctx, authContextKey, auth)
+}
+
+// authFromContext returns the identity information stored by WithAuth,
+// or nil if there isn't any
+func authFromContext(ctx context.Context) *EventAuth {
+	auth, _ := ctx.Value(authContextKey).(*EventAuth)
+	return auth
+}
+
+// IdentityExtractor returns the identity of the caller of an inbound HTTP
+// request, or nil if it can't be identified. See WithIdentityExtractor.
+type IdentityExtractor func(req *http.Request) *EventAuth
+
+// WithIdentityExtractor returns a MiddlewareOption which runs f for each
+// request, and stores the identity it returns in the request context using
+// WithAuth. The identity is then included in the events logged by Middleware,
+// and in every other event logged with the request context.
+func WithIdentityExtractor(f IdentityExtractor) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.identityExtractor = f
+	}
+}
+
+// IdentityFromRequestContext is an IdentityExtractor which uses the user or
+// caller identity stored in the request context by the dp-net identity
+// handlers. A user identity is preferred over a caller (service) identity.
+func IdentityFromRequestContext(req *http.Request) *EventAuth {
+	if user := request.User(req.Context()); user != "" {
+		return &EventAuth{Identity: user, IdentityType: USER}
+	}
+	if caller := request.Caller(req.Context()); caller != "" {
+		return &EventAuth{Identity: caller, IdentityType: SERVICE}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AzureEncoder encodes events with the fields Azure Monitor and Application
+// Insights use for traces: time, message, severityLevel, cloud_RoleName for
+// the namespace, operation_Id and operation_ParentId for the trace and span
+// IDs, and customDimensions for Data
+var AzureEncoder Encoder = func(e EventData) ([]byte, error) {
+	return json.Marshal(newAzureEvent(e))
+}
+
+type azureEvent struct {
+	Time              string       `json:"time"`
+	Level             string       `json:"level,omitempty"`
+	SeverityLevel     *int         `json:"severityLevel,omitempty"`
+	Message           string       `json:"message"`
+	CloudRoleName     string       `json:"cloud_RoleName"`
+	OperationID       string       `json:"operation_Id,omitempty"`
+	OperationParentID string       `json:"operation_ParentId,omitempty"`
+	OperationName     string       `json:"operation_Name,omitempty"`
+	CustomDimensions  *Data        `json:"customDimensions,omitempty"`
+	Buffered          bool         `json:"buffered,omitempty"`
+	HTTP              *EventHTTP   `json:"http,omitempty"`
+	GRPC              *EventGRPC   `json:"grpc,omitempty"`
+	Auth              *EventAuth   `json:"auth,omitempty"`
+	Errors            *EventErrors `json:"errors,omitempty"`
+}
+
+// azureSeverity maps a severity to an Application Insights severity level
+// and the corresponding Azure Monitor level name
+func azureSeverity(s severity) (int, string) {
+	switch s {
+	case FATAL:
+		return 4, "Critical"
+	case ERROR:
+		return 3, "Error"
+	case WARN:
+		return 2, "Warning"
+	case INFO:
+		return 1, "Information"
+	}
+	return 0, "Verbose"
+}
+
+func newAzureEvent(e EventData) azureEvent {
+	a := azureEvent{
+		Time:              e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Message:           e.Event,
+		CloudRoleName:     e.Namespace,
+		OperationID:       e.TraceID,
+		OperationParentID: e.SpanID,
+		CustomDimensions:  e.Data,
+		Buffered:          e.Buffered,
+		HTTP:              e.HTTP,
+		GRPC:              e.GRPC,
+		Auth:              e.Auth,
+		Errors:            e.Errors,
+	}
+
+	if e.Severity != nil {
+		level, name := azureSeverity(*e.Severity)
+		a.SeverityLevel = &level
+		a.Level = name
+	}
+
+	if e.HTTP != nil && e.HTTP.Method != "" {
+		a.OperationName = e.HTTP.Method + " " + e.HTTP.Path
+	} else if e.GRPC != nil && e.GRPC.Method != "" {
+		a.OperationName = e.GRPC.Service + "/" + e.GRPC.Method
+	}
+
+	return a
+}
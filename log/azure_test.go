@@ -0,0 +1,58 @@
+package log
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAzureEncoder(t *testing.T) {
+	Convey("AzureEncoder encodes an event with Azure Monitor fields", t, func() {
+		sev := WARN
+		e := EventData{
+			CreatedAt: time.Date(2020, 12, 10, 11, 16, 39, 156000000, time.UTC),
+			Namespace: "my-service",
+			Event:     "slow request",
+			TraceID:   "abc123",
+			SpanID:    "def456",
+			Severity:  &sev,
+			HTTP:      &EventHTTP{Method: "GET", Path: "/foo"},
+			Data:      &Data{"key": "value"},
+		}
+
+		b, err := AzureEncoder(e)
+		So(err, ShouldBeNil)
+
+		var m map[string]interface{}
+		So(json.Unmarshal(b, &m), ShouldBeNil)
+		So(m["time"], ShouldEqual, "2020-12-10T11:16:39.156Z")
+		So(m["message"], ShouldEqual, "slow request")
+		So(m["level"], ShouldEqual, "Warning")
+		So(m["severityLevel"], ShouldEqual, 2)
+		So(m["cloud_RoleName"], ShouldEqual, "my-service")
+		So(m["operation_Id"], ShouldEqual, "abc123")
+		So(m["operation_ParentId"], ShouldEqual, "def456")
+		So(m["operation_Name"], ShouldEqual, "GET /foo")
+		So(m["customDimensions"], ShouldResemble, map[string]interface{}{"key": "value"})
+		So(m, ShouldNotContainKey, "buffered")
+
+		Convey("And marks events from a debug buffer as buffered", func() {
+			e.Buffered = true
+			b, err := AzureEncoder(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["buffered"], ShouldBeTrue)
+		})
+	})
+
+	Convey("Severities are mapped to Application Insights severity levels", t, func() {
+		for s, level := range map[severity]int{FATAL: 4, ERROR: 3, WARN: 2, INFO: 1, DEBUG: 0, TRACE: 0} {
+			l, _ := azureSeverity(s)
+			So(l, ShouldEqual, level)
+		}
+	})
+}
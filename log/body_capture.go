@@ -0,0 +1,192 @@
+package log
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultBodyCaptureMaxBytes is the number of bytes of each request and
+// response body captured if BodyCapture.MaxBytes isn't set
+const DefaultBodyCaptureMaxBytes = 4096
+
+// DefaultBodyCaptureContentTypes are the content types captured if
+// BodyCapture.ContentTypes isn't set
+var DefaultBodyCaptureContentTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/xml",
+	"application/x-www-form-urlencoded",
+	"text/*",
+}
+
+// BodyCapture configures the request and response body capture enabled
+// by passing WithBodyCapture to Middleware.
+//
+// Bodies are copied as they are read by the handler or written to the
+// client, so streamed requests and responses are not delayed or buffered
+// in full. Only the first MaxBytes bytes of each body are kept.
+type BodyCapture struct {
+	// MaxBytes is the maximum number of bytes captured from each body.
+	// DefaultBodyCaptureMaxBytes is used if it is zero or less.
+	MaxBytes int64
+
+	// ContentTypes is the list of media types which are captured, for
+	// example "application/json". A subtype of "*" matches any subtype,
+	// e.g. "text/*". DefaultBodyCaptureContentTypes is used if it is empty.
+	ContentTypes []string
+
+	// OnlyOnError only includes bodies in the completed event if the
+	// response status code is 400 or above.
+	OnlyOnError bool
+}
+
+// WithBodyCapture returns a MiddlewareOption which includes request and
+// response bodies in the "http request completed" event.
+//
+// Bodies can include personal or sensitive data, so this should only be
+// enabled for internal APIs where that is acceptable.
+func WithBodyCapture(bc BodyCapture) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.bodyCapture = &bc
+	}
+}
+
+// EventHTTPBody is the data structure used for logging a captured
+// request or response body.
+//
+// It isn't very useful to export, other than for documenting the
+// data structure it outputs.
+type EventHTTPBody struct {
+	ContentType string `json:"content_type,omitempty"`
+	Content     string `json:"content"`
+	Size        int64  `json:"size"`
+	Truncated   bool   `json:"truncated,omitempty"`
+}
+
+func (bc *BodyCapture) maxBytes() int64 {
+	if bc.MaxBytes <= 0 {
+		return DefaultBodyCaptureMaxBytes
+	}
+	return bc.MaxBytes
+}
+
+func (bc *BodyCapture) shouldLog(statusCode int) bool {
+	return !bc.OnlyOnError || statusCode >= http.StatusBadRequest
+}
+
+// allowsContentType returns true if the media type in the Content-Type
+// header value ct matches one of the allowed content types
+func (bc *BodyCapture) allowsContentType(ct string) bool {
+	if ct == "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	allowed := bc.ContentTypes
+	if len(allowed) == 0 {
+		allowed = DefaultBodyCaptureContentTypes
+	}
+
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wrapRequest replaces the request body with one which copies data into
+// a bodyBuffer as it is read. It returns nil if the body isn't captured.
+func (bc *BodyCapture) wrapRequest(req *http.Request) *bodyBuffer {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	ct := req.Header.Get("Content-Type")
+	if !bc.allowsContentType(ct) {
+		return nil
+	}
+
+	buf := newBodyBuffer(ct, bc.maxBytes())
+	req.Body = &teeReadCloser{req.Body, buf}
+	return buf
+}
+
+// bodyBuffer is an io.Writer which keeps the first max bytes written to it,
+// and counts (but discards) everything after that
+type bodyBuffer struct {
+	mu          sync.Mutex
+	contentType string
+	max         int64
+	size        int64
+	buf         []byte
+}
+
+func newBodyBuffer(contentType string, max int64) *bodyBuffer {
+	return &bodyBuffer{contentType: contentType, max: max}
+}
+
+func (b *bodyBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if remaining := b.max - int64(len(b.buf)); remaining > 0 {
+		if int64(len(p)) > remaining {
+			b.buf = append(b.buf, p[:remaining]...)
+		} else {
+			b.buf = append(b.buf, p...)
+		}
+	}
+	b.size += int64(len(p))
+
+	return len(p), nil
+}
+
+// eventBody returns the captured body for logging, or nil if nothing
+// was captured
+func (b *bodyBuffer) eventBody() *EventHTTPBody {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.size == 0 {
+		return nil
+	}
+
+	return &EventHTTPBody{
+		ContentType: b.contentType,
+		Content:     string(b.buf),
+		Size:        b.size,
+		Truncated:   b.size > int64(len(b.buf)),
+	}
+}
+
+// teeReadCloser writes everything read from the wrapped io.ReadCloser
+// to w, in the same way as io.TeeReader
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (n int, err error) {
+	n, err = t.ReadCloser.Read(p)
+	if n > 0 {
+		t.w.Write(p[:n]) //nolint:errcheck // the writer is always a bodyBuffer, which never returns an error
+	}
+	return
+}
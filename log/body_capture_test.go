@@ -0,0 +1,156 @@
+package log
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBodyCapture(t *testing.T) {
+	Convey("allowsContentType matches allowed media types", t, func() {
+		bc := &BodyCapture{}
+		So(bc.allowsContentType("application/json"), ShouldBeTrue)
+		So(bc.allowsContentType("application/json; charset=utf-8"), ShouldBeTrue)
+		So(bc.allowsContentType("text/plain"), ShouldBeTrue)
+		So(bc.allowsContentType("application/octet-stream"), ShouldBeFalse)
+		So(bc.allowsContentType(""), ShouldBeFalse)
+		So(bc.allowsContentType("not a media type;;"), ShouldBeFalse)
+
+		bc = &BodyCapture{ContentTypes: []string{"image/*"}}
+		So(bc.allowsContentType("image/png"), ShouldBeTrue)
+		So(bc.allowsContentType("application/json"), ShouldBeFalse)
+	})
+
+	Convey("bodyBuffer keeps the first max bytes", t, func() {
+		b := newBodyBuffer("text/plain", 5)
+		So(b.eventBody(), ShouldBeNil)
+
+		b.Write([]byte("abc"))
+		b.Write([]byte("defgh"))
+
+		eb := b.eventBody()
+		So(eb, ShouldNotBeNil)
+		So(eb.Content, ShouldEqual, "abcde")
+		So(eb.Size, ShouldEqual, 8)
+		So(eb.Truncated, ShouldBeTrue)
+		So(eb.ContentType, ShouldEqual, "text/plain")
+	})
+
+	Convey("a nil bodyBuffer returns a nil event body", t, func() {
+		var b *bodyBuffer
+		So(b.eventBody(), ShouldBeNil)
+	})
+}
+
+func TestMiddlewareBodyCapture(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("Given a middleware with body capture enabled", t, func() {
+		var completed *EventHTTP
+		mock.onEvent = func(e eventFuncMock) {
+			if e.capEvent == "http request completed" {
+				completed = e.capOpts[0].(*EventHTTP)
+			}
+		}
+
+		status := http.StatusOK
+		var readBody string
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			b, _ := io.ReadAll(req.Body)
+			readBody = string(b)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"response":true}`))
+		})
+
+		newRequest := func() *http.Request {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(`{"request":true}`))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		}
+
+		Convey("When the request completes, the bodies are included in the completed event", func() {
+			rec := httptest.NewRecorder()
+			Middleware(h, WithBodyCapture(BodyCapture{})).ServeHTTP(rec, newRequest())
+
+			So(readBody, ShouldEqual, `{"request":true}`)
+			So(rec.Body.String(), ShouldEqual, `{"response":true}`)
+			So(completed, ShouldNotBeNil)
+			So(completed.RequestBody, ShouldNotBeNil)
+			So(completed.RequestBody.Content, ShouldEqual, `{"request":true}`)
+			So(completed.ResponseBody, ShouldNotBeNil)
+			So(completed.ResponseBody.Content, ShouldEqual, `{"response":true}`)
+			So(completed.ResponseBody.Truncated, ShouldBeFalse)
+		})
+
+		Convey("When the body is larger than MaxBytes, the captured body is truncated", func() {
+			rec := httptest.NewRecorder()
+			Middleware(h, WithBodyCapture(BodyCapture{MaxBytes: 4})).ServeHTTP(rec, newRequest())
+
+			So(rec.Body.String(), ShouldEqual, `{"response":true}`)
+			So(completed.ResponseBody.Content, ShouldEqual, `{"re`)
+			So(completed.ResponseBody.Size, ShouldEqual, 17)
+			So(completed.ResponseBody.Truncated, ShouldBeTrue)
+		})
+
+		Convey("When the content type isn't allowed, bodies aren't captured", func() {
+			rec := httptest.NewRecorder()
+			Middleware(h, WithBodyCapture(BodyCapture{ContentTypes: []string{"text/csv"}})).ServeHTTP(rec, newRequest())
+
+			So(completed.RequestBody, ShouldBeNil)
+			So(completed.ResponseBody, ShouldBeNil)
+		})
+
+		Convey("When only capturing on error", func() {
+			m := Middleware(h, WithBodyCapture(BodyCapture{OnlyOnError: true}))
+
+			Convey("Bodies aren't included for a successful response", func() {
+				m.ServeHTTP(httptest.NewRecorder(), newRequest())
+				So(completed.RequestBody, ShouldBeNil)
+				So(completed.ResponseBody, ShouldBeNil)
+			})
+
+			Convey("Bodies are included for an error response", func() {
+				status = http.StatusInternalServerError
+				m.ServeHTTP(httptest.NewRecorder(), newRequest())
+				So(completed.RequestBody, ShouldNotBeNil)
+				So(completed.ResponseBody, ShouldNotBeNil)
+				So(completed.ResponseBody.Content, ShouldEqual, `{"response":true}`)
+			})
+		})
+
+		Convey("When the handler doesn't set a content type, it is sniffed", func() {
+			h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte(`{"response":true}`))
+			})
+			// the server is used rather than a ResponseRecorder, since the
+			// recorder adds the sniffed Content-Type to the handler's headers
+			srv := httptest.NewServer(Middleware(h, WithBodyCapture(BodyCapture{})))
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+
+			So(completed, ShouldNotBeNil)
+			So(completed.ResponseBody, ShouldNotBeNil)
+			So(completed.ResponseBody.Content, ShouldEqual, `{"response":true}`)
+			So(completed.ResponseBody.ContentType, ShouldEqual, "text/plain; charset=utf-8")
+		})
+
+		Convey("Without body capture, bodies aren't included", func() {
+			Middleware(h).ServeHTTP(httptest.NewRecorder(), newRequest())
+			So(completed.RequestBody, ShouldBeNil)
+			So(completed.ResponseBody, ShouldBeNil)
+		})
+	})
+}
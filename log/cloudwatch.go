@@ -0,0 +1,99 @@
+package log
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// CloudWatchOptions configures CloudWatchEncoder
+type CloudWatchOptions struct {
+	// EMF adds Embedded Metric Format metadata to the "http request
+	// completed" events logged by Middleware, so that CloudWatch creates a
+	// RequestDuration metric in milliseconds with the Service and StatusCode
+	// dimensions
+	EMF bool
+
+	// MetricNamespace is the CloudWatch metric namespace, which defaults to
+	// the log namespace
+	MetricNamespace string
+}
+
+// CloudWatchEncoder returns an encoder for AWS CloudWatch Logs. The default
+// fields are included along with a level string, message and timestamp,
+// which CloudWatch Logs Insights recognises.
+func CloudWatchEncoder(opts CloudWatchOptions) Encoder {
+	return func(e EventData) ([]byte, error) {
+		cw := cloudWatchFields{
+			Timestamp: e.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+			Message:   e.Event,
+		}
+		if e.Severity != nil {
+			cw.Level = e.Severity.name()
+		}
+
+		// other events with HTTP data, e.g. outbound requests, would skew the metric
+		if opts.EMF && e.Event == httpRequestCompletedEvent && e.HTTP != nil && e.HTTP.Duration != nil {
+			namespace := opts.MetricNamespace
+			if namespace == "" {
+				namespace = e.Namespace
+			}
+			cw.addRequestDurationMetric(e, namespace)
+		}
+
+		return json.Marshal(struct {
+			cloudWatchFields
+			EventData
+		}{cw, e})
+	}
+}
+
+// cloudWatchFields are added to each event by CloudWatchEncoder
+type cloudWatchFields struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level,omitempty"`
+	Message   string `json:"message"`
+
+	// Embedded Metric Format metadata and values
+	AWS             *emfMetadata `json:"_aws,omitempty"`
+	Service         string       `json:"Service,omitempty"`
+	StatusCode      string       `json:"StatusCode,omitempty"`
+	RequestDuration *float64     `json:"RequestDuration,omitempty"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64                 `json:"Timestamp"`
+	CloudWatchMetrics []emfMetricDirectives `json:"CloudWatchMetrics"`
+}
+
+type emfMetricDirectives struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+func (cw *cloudWatchFields) addRequestDurationMetric(e EventData, namespace string) {
+	ms := float64(*e.HTTP.Duration) / float64(time.Millisecond)
+	cw.RequestDuration = &ms
+	cw.Service = e.Namespace
+
+	dimensions := []string{"Service"}
+	if e.HTTP.StatusCode != nil && *e.HTTP.StatusCode != 0 {
+		cw.StatusCode = strconv.Itoa(*e.HTTP.StatusCode)
+		dimensions = append(dimensions, "StatusCode")
+	}
+
+	cw.AWS = &emfMetadata{
+		Timestamp: e.CreatedAt.UnixMilli(),
+		CloudWatchMetrics: []emfMetricDirectives{{
+			Namespace:  namespace,
+			Dimensions: [][]string{dimensions},
+			Metrics:    []emfMetric{{Name: "RequestDuration", Unit: "Milliseconds"}},
+		}},
+	}
+}
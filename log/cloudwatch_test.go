@@ -0,0 +1,74 @@
+package log
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCloudWatchEncoder(t *testing.T) {
+	Convey("Given an HTTP event", t, func() {
+		sev := INFO
+		status := 200
+		duration := 1500 * time.Microsecond
+		e := EventData{
+			CreatedAt: time.Date(2020, 12, 10, 11, 16, 39, 156000000, time.UTC),
+			Namespace: "my-service",
+			Event:     "http request completed",
+			Severity:  &sev,
+			HTTP:      &EventHTTP{Method: "GET", Path: "/foo", StatusCode: &status, Duration: &duration},
+		}
+
+		Convey("CloudWatchEncoder adds a level, message and timestamp", func() {
+			b, err := CloudWatchEncoder(CloudWatchOptions{})(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["level"], ShouldEqual, "INFO")
+			So(m["message"], ShouldEqual, "http request completed")
+			So(m["timestamp"], ShouldEqual, "2020-12-10T11:16:39.156Z")
+			So(m["event"], ShouldEqual, "http request completed")
+			So(m["severity"], ShouldEqual, 3)
+			So(m, ShouldNotContainKey, "_aws")
+		})
+
+		Convey("CloudWatchEncoder with EMF adds a request duration metric", func() {
+			b, err := CloudWatchEncoder(CloudWatchOptions{EMF: true, MetricNamespace: "Web"})(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["RequestDuration"], ShouldEqual, 1.5)
+			So(m["Service"], ShouldEqual, "my-service")
+			So(m["StatusCode"], ShouldEqual, "200")
+			So(m["_aws"], ShouldResemble, map[string]interface{}{
+				"Timestamp": float64(e.CreatedAt.UnixMilli()),
+				"CloudWatchMetrics": []interface{}{map[string]interface{}{
+					"Namespace":  "Web",
+					"Dimensions": []interface{}{[]interface{}{"Service", "StatusCode"}},
+					"Metrics":    []interface{}{map[string]interface{}{"Name": "RequestDuration", "Unit": "Milliseconds"}},
+				}},
+			})
+
+			Convey("But not to events without HTTP data", func() {
+				e.HTTP = nil
+
+				b, err := CloudWatchEncoder(CloudWatchOptions{EMF: true})(e)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldNotContainSubstring, "_aws")
+			})
+
+			Convey("But not to other HTTP events with a duration", func() {
+				e.Event = "outbound request completed"
+
+				b, err := CloudWatchEncoder(CloudWatchOptions{EMF: true})(e)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldNotContainSubstring, "_aws")
+				So(string(b), ShouldNotContainSubstring, "RequestDuration")
+			})
+		})
+	})
+}
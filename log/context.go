@@ -0,0 +1,11 @@
+package log
+
+// contextKey is the type of keys used to store log data in a context,
+// so they can't collide with keys from other packages
+type contextKey int
+
+const (
+	authContextKey contextKey = iota
+	debugBufferContextKey
+	minimumSeverityContextKey
+)
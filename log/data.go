@@ -17,3 +17,33 @@ type Data map[string]interface{}
 func (d Data) attach(le *EventData) {
 	le.Data = &d
 }
+
+// copyData returns a copy of d, including any nested Data, maps and slices,
+// so that it isn't changed if the caller reuses d after logging it
+func copyData(d Data) Data {
+	if d == nil {
+		return nil
+	}
+	c := make(Data, len(d))
+	for k, v := range d {
+		c[k] = copyDataValue(v)
+	}
+	return c
+}
+
+func copyDataValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case Data:
+		return copyData(v)
+	case map[string]interface{}:
+		return map[string]interface{}(copyData(v))
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i := range v {
+			c[i] = copyDataValue(v[i])
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,120 @@
+package log
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultDebugBufferSize is the number of events kept by a debug buffer
+// when a size of zero or less is given
+const DefaultDebugBufferSize = 100
+
+// debugBuffer holds events which are less severe than the minimum severity,
+// so they can be logged later if the work they relate to fails
+type debugBuffer struct {
+	mu      sync.Mutex
+	size    int
+	events  []*EventData
+	dropped int
+}
+
+// ContextWithDebugBuffer returns a copy of ctx with a debug buffer attached.
+//
+// Events logged with the returned context which are less severe than the
+// minimum severity (see SetMinimumSeverity) are kept in the buffer instead
+// of being discarded. If an ERROR or FATAL event is logged with the context,
+// or FlushDebugBuffer is called, the buffered events are logged in order with
+// "buffered": true. Otherwise they are discarded when the context is no
+// longer used, or when DiscardDebugBuffer is called.
+//
+// Only the most recent size events are kept. See also WithDebugBuffer, which
+// adds a debug buffer to each request handled by Middleware.
+func ContextWithDebugBuffer(ctx context.Context, size int) context.Context {
+	if size <= 0 {
+		size = DefaultDebugBufferSize
+	}
+	return context.WithValue(ctx, debugBufferContextKey, &debugBuffer{size: size})
+}
+
+// FlushDebugBuffer logs any events held in the debug buffer attached to ctx,
+// and empties the buffer
+func FlushDebugBuffer(ctx context.Context) {
+	if b := debugBufferFromContext(ctx); b != nil {
+		b.flush(ctx)
+	}
+}
+
+// DiscardDebugBuffer empties the debug buffer attached to ctx without
+// logging the events it holds
+func DiscardDebugBuffer(ctx context.Context) {
+	if b := debugBufferFromContext(ctx); b != nil {
+		b.take()
+	}
+}
+
+// WithDebugBuffer returns a MiddlewareOption which attaches a debug buffer of
+// the given size to each request context (see ContextWithDebugBuffer).
+//
+// The buffered events are logged if the response status code is 500 or
+// above, or if an ERROR event is logged with the request context, and are
+// discarded otherwise.
+func WithDebugBuffer(size int) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		if size <= 0 {
+			size = DefaultDebugBufferSize
+		}
+		cfg.debugBufferSize = size
+	}
+}
+
+func debugBufferFromContext(ctx context.Context) *debugBuffer {
+	if ctx == nil {
+		return nil
+	}
+	b, _ := ctx.Value(debugBufferContextKey).(*debugBuffer)
+	return b
+}
+
+// add appends a snapshot of e to the buffer, dropping the oldest event if
+// it is full
+func (b *debugBuffer) add(e *EventData) {
+	e = e.snapshot()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.events) >= b.size {
+		b.events = b.events[1:]
+		b.dropped++
+	}
+	b.events = append(b.events, e)
+}
+
+// take empties the buffer, and returns the events it held and the number
+// of events which were dropped because it was full
+func (b *debugBuffer) take() (events []*EventData, dropped int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events, dropped = b.events, b.dropped
+	b.events, b.dropped = nil, 0
+	return events, dropped
+}
+
+// flush logs the buffered events in order, and empties the buffer
+func (b *debugBuffer) flush(ctx context.Context) {
+	events, dropped := b.take()
+	if len(events) == 0 {
+		return
+	}
+
+	if dropped > 0 {
+		ev := createEvent(ctx, "debug buffer full, oldest events dropped", WARN, Data{"dropped": dropped})
+		ev.Buffered = true
+		writeEvent(ctx, ev)
+	}
+
+	for _, e := range events {
+		writeEvent(ctx, e)
+	}
+}
@@ -0,0 +1,164 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// loggedEvents decodes each line written to buf as an event
+func loggedEvents(buf *bytes.Buffer) []map[string]interface{} {
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err == nil {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func eventNames(events []map[string]interface{}) []string {
+	names := make([]string, 0, len(events))
+	for _, e := range events {
+		names = append(names, e["event"].(string))
+	}
+	return names
+}
+
+func TestDebugBuffer(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+
+	Convey("Given a context with a debug buffer", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+		ctx := ContextWithDebugBuffer(context.Background(), 2)
+
+		Convey("DEBUG events are not logged immediately", func() {
+			Debug(ctx, "debug 1")
+			So(buf.Len(), ShouldEqual, 0)
+
+			Convey("And are logged in order before an ERROR event", func() {
+				Debug(ctx, "debug 2")
+				Info(ctx, "info")
+				Error(ctx, "error", errors.New("error"))
+
+				events := loggedEvents(buf)
+				So(eventNames(events), ShouldResemble, []string{"info", "debug 1", "debug 2", "error"})
+				So(events[1]["buffered"], ShouldBeTrue)
+				So(events[1]["severity"], ShouldEqual, DEBUG)
+				So(events[0]["buffered"], ShouldBeNil)
+				So(events[3]["buffered"], ShouldBeNil)
+
+				Convey("And the buffer is emptied", func() {
+					buf.Reset()
+					FlushDebugBuffer(ctx)
+					So(buf.Len(), ShouldEqual, 0)
+				})
+			})
+
+			Convey("And are logged when flushed", func() {
+				FlushDebugBuffer(ctx)
+				So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"debug 1"})
+			})
+
+			Convey("And are not logged when discarded", func() {
+				DiscardDebugBuffer(ctx)
+				FlushDebugBuffer(ctx)
+				So(buf.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("Buffered events aren't changed if the caller reuses their data", func() {
+			data := Data{"key": "before", "nested": Data{"key": "before"}}
+			Debug(ctx, "debug", data)
+			data["key"] = "after"
+			data["nested"].(Data)["key"] = "after"
+			FlushDebugBuffer(ctx)
+
+			events := loggedEvents(buf)
+			So(events, ShouldHaveLength, 1)
+			So(events[0]["data"], ShouldResemble, map[string]interface{}{
+				"key":    "before",
+				"nested": map[string]interface{}{"key": "before"},
+			})
+		})
+
+		Convey("When more events than the buffer size are logged, the oldest are dropped", func() {
+			Debug(ctx, "debug 1")
+			Debug(ctx, "debug 2")
+			Debug(ctx, "debug 3")
+			FlushDebugBuffer(ctx)
+
+			events := loggedEvents(buf)
+			So(eventNames(events), ShouldResemble, []string{"debug buffer full, oldest events dropped", "debug 2", "debug 3"})
+			So(events[0]["data"].(map[string]interface{})["dropped"], ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a context without a debug buffer", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+
+		Convey("DEBUG events are discarded", func() {
+			Debug(context.Background(), "debug")
+			Error(context.Background(), "error", nil)
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"error"})
+		})
+	})
+}
+
+func TestMiddlewareDebugBuffer(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+
+	Convey("Given a middleware with a debug buffer", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+
+		status := http.StatusOK
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			Debug(req.Context(), "handler debug")
+			w.WriteHeader(status)
+		})
+		m := Middleware(h, WithDebugBuffer(0))
+
+		Convey("When the request succeeds, the debug events are discarded", func() {
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"http request received", "http request completed"})
+		})
+
+		Convey("When the request fails, the debug events are logged before the completed event", func() {
+			status = http.StatusInternalServerError
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"http request received", "handler debug", "http request completed"})
+		})
+	})
+}
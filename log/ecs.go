@@ -0,0 +1,136 @@
+package log
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ECSVersion is the version of the Elastic Common Schema used by ECSEncoder
+const ECSVersion = "8.11.0"
+
+var (
+	// ECSEncoder encodes events using Elastic Common Schema fields, e.g.
+	//
+	//	{"@timestamp":"2020-12-10T11:16:39.156Z","log.level":"error","message":"unexpected error","ecs.version":"8.11.0",...}
+	//
+	// Data is included as the data object, which isn't part of ECS.
+	ECSEncoder Encoder = func(e EventData) ([]byte, error) {
+		return json.Marshal(struct {
+			ecsEvent
+			Data *Data `json:"data,omitempty"`
+		}{newECSEvent(e), e.Data})
+	}
+
+	// ECSCompatibleEncoder encodes events with both the Elastic Common Schema
+	// fields and the default fields, so that existing index mappings and
+	// queries continue to work while moving to ECS.
+	//
+	// The event.start, event.end and event.duration fields are left out,
+	// since Elasticsearch can't map event as both the default event name
+	// string and an object. The default http fields include the same times.
+	ECSCompatibleEncoder Encoder = func(e EventData) ([]byte, error) {
+		ecs := newECSEvent(e)
+		ecs.EventStart, ecs.EventEnd, ecs.EventDuration = "", "", 0
+		return json.Marshal(struct {
+			ecsEvent
+			EventData
+		}{ecs, e})
+	}
+)
+
+// ecsEvent contains the Elastic Common Schema fields for an event. The
+// ecs-logging specification recommends @timestamp, log.level and message
+// are the first fields.
+type ecsEvent struct {
+	Timestamp  string `json:"@timestamp"`
+	LogLevel   string `json:"log.level,omitempty"`
+	Message    string `json:"message"`
+	ECSVersion string `json:"ecs.version"`
+
+	ServiceName string `json:"service.name,omitempty"`
+	TraceID     string `json:"trace.id,omitempty"`
+	SpanID      string `json:"span.id,omitempty"`
+
+	HTTPRequestMethod      string `json:"http.request.method,omitempty"`
+	HTTPResponseStatusCode int    `json:"http.response.status_code,omitempty"`
+	HTTPResponseBodyBytes  int64  `json:"http.response.body.bytes,omitempty"`
+	URLScheme              string `json:"url.scheme,omitempty"`
+	URLDomain              string `json:"url.domain,omitempty"`
+	URLPort                int    `json:"url.port,omitempty"`
+	URLPath                string `json:"url.path,omitempty"`
+	URLQuery               string `json:"url.query,omitempty"`
+	EventStart             string `json:"event.start,omitempty"`
+	EventEnd               string `json:"event.end,omitempty"`
+	EventDuration          int64  `json:"event.duration,omitempty"`
+
+	UserID    string   `json:"user.id,omitempty"`
+	UserRoles []string `json:"user.roles,omitempty"`
+
+	ErrorMessage    string `json:"error.message,omitempty"`
+	ErrorStackTrace string `json:"error.stack_trace,omitempty"`
+
+	// labels are keywords, so this is "true" rather than a boolean
+	LabelsBuffered string `json:"labels.buffered,omitempty"`
+}
+
+// ecsTimestamp formats t as ECS expects, with millisecond precision or better
+func ecsTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+}
+
+// newECSEvent maps an event to Elastic Common Schema fields
+func newECSEvent(e EventData) ecsEvent {
+	ecs := ecsEvent{
+		Timestamp:   ecsTimestamp(e.CreatedAt),
+		Message:     e.Event,
+		ECSVersion:  ECSVersion,
+		ServiceName: e.Namespace,
+		TraceID:     e.TraceID,
+		SpanID:      e.SpanID,
+	}
+
+	if e.Severity != nil {
+		ecs.LogLevel = strings.ToLower(e.Severity.name())
+	}
+
+	if e.HTTP != nil {
+		ecs.HTTPRequestMethod = e.HTTP.Method
+		if e.HTTP.StatusCode != nil {
+			ecs.HTTPResponseStatusCode = *e.HTTP.StatusCode
+		}
+		ecs.HTTPResponseBodyBytes = e.HTTP.ResponseContentLength
+		ecs.URLScheme = e.HTTP.Scheme
+		ecs.URLDomain = e.HTTP.Host
+		ecs.URLPort = e.HTTP.Port
+		ecs.URLPath = e.HTTP.Path
+		ecs.URLQuery = e.HTTP.Query
+		if e.HTTP.StartedAt != nil {
+			ecs.EventStart = ecsTimestamp(*e.HTTP.StartedAt)
+		}
+		if e.HTTP.EndedAt != nil {
+			ecs.EventEnd = ecsTimestamp(*e.HTTP.EndedAt)
+		}
+		if e.HTTP.Duration != nil {
+			// event.duration is in nanoseconds
+			ecs.EventDuration = e.HTTP.Duration.Nanoseconds()
+		}
+	}
+
+	if e.Auth != nil {
+		ecs.UserID = e.Auth.Identity
+		ecs.UserRoles = e.Auth.Roles
+	}
+
+	if e.Errors != nil && len(*e.Errors) > 0 {
+		// ECS has a single error, so the first error is used
+		ecs.ErrorMessage = (*e.Errors)[0].Message
+		ecs.ErrorStackTrace = formatStackTrace((*e.Errors)[0].StackTrace)
+	}
+
+	if e.Buffered {
+		ecs.LabelsBuffered = "true"
+	}
+
+	return ecs
+}
@@ -0,0 +1,99 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestECSEncoder(t *testing.T) {
+	Convey("Given an event", t, func() {
+		sev := ERROR
+		status := 500
+		duration := 1500 * time.Millisecond
+		e := EventData{
+			CreatedAt: time.Date(2020, 12, 10, 11, 16, 39, 156000000, time.UTC),
+			Namespace: "my-service",
+			Event:     "request failed",
+			TraceID:   "abc123",
+			Severity:  &sev,
+			HTTP:      &EventHTTP{Method: "GET", Path: "/foo", Query: "a=1", StatusCode: &status, Duration: &duration},
+			Auth:      &EventAuth{Identity: "user@example.com", Roles: []string{"admin"}},
+			Data:      &Data{"key": "value"},
+			Errors:    FormatErrors([]error{errors.New("test error")}).(*EventErrors),
+		}
+
+		Convey("ECSEncoder encodes it with Elastic Common Schema fields", func() {
+			b, err := ECSEncoder(e)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldStartWith, `{"@timestamp":"2020-12-10T11:16:39.156000000Z","log.level":"error","message":"request failed","ecs.version":"8.11.0",`)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["service.name"], ShouldEqual, "my-service")
+			So(m["trace.id"], ShouldEqual, "abc123")
+			So(m["http.request.method"], ShouldEqual, "GET")
+			So(m["http.response.status_code"], ShouldEqual, 500)
+			So(m["url.path"], ShouldEqual, "/foo")
+			So(m["url.query"], ShouldEqual, "a=1")
+			So(m["event.duration"], ShouldEqual, 1500000000)
+			So(m["user.id"], ShouldEqual, "user@example.com")
+			So(m["user.roles"], ShouldResemble, []interface{}{"admin"})
+			So(m["error.message"], ShouldEqual, "test error")
+			So(m["error.stack_trace"], ShouldContainSubstring, "ecs_test.go")
+			So(m["data"], ShouldResemble, map[string]interface{}{"key": "value"})
+
+			Convey("And without the default fields", func() {
+				So(m, ShouldNotContainKey, "created_at")
+				So(m, ShouldNotContainKey, "event")
+				So(m, ShouldNotContainKey, "severity")
+				So(m, ShouldNotContainKey, "labels.buffered")
+			})
+		})
+
+		Convey("ECSEncoder labels events from a debug buffer", func() {
+			e.Buffered = true
+			b, err := ECSEncoder(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["labels.buffered"], ShouldEqual, "true")
+		})
+
+		Convey("ECSCompatibleEncoder encodes it with both sets of fields", func() {
+			b, err := ECSCompatibleEncoder(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["@timestamp"], ShouldEqual, "2020-12-10T11:16:39.156000000Z")
+			So(m["message"], ShouldEqual, "request failed")
+			So(m["log.level"], ShouldEqual, "error")
+			So(m["created_at"], ShouldEqual, "2020-12-10T11:16:39.156Z")
+			So(m["event"], ShouldEqual, "request failed")
+			So(m["severity"], ShouldEqual, 1)
+			So(m["data"], ShouldResemble, map[string]interface{}{"key": "value"})
+			So(m["errors"], ShouldHaveLength, 1)
+
+			Convey("And Elasticsearch can map every field", func() {
+				// a dotted key is mapped as a field within an object, so any
+				// other key for that object must also be an object
+				for k := range m {
+					parts := strings.Split(k, ".")
+					for i := 1; i < len(parts); i++ {
+						prefix := strings.Join(parts[:i], ".")
+						if v, ok := m[prefix]; ok {
+							So(v, ShouldHaveSameTypeAs, map[string]interface{}{})
+						}
+					}
+				}
+				So(m, ShouldNotContainKey, "event.duration")
+			})
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hokaccha/go-prettyjson"
+)
+
+// Encoder converts an event to the bytes written to a destination,
+// not including the trailing newline
+type Encoder func(e EventData) ([]byte, error)
+
+var (
+	// JSONEncoder encodes events in JSONLines format, which is the
+	// default format for log output
+	JSONEncoder Encoder = func(e EventData) ([]byte, error) { return json.Marshal(e) }
+
+	// HumanEncoder encodes events as syntax highlighted pretty printed JSON,
+	// which is the format used when the HUMAN_LOG environment variable is set
+	HumanEncoder Encoder = func(e EventData) ([]byte, error) { return prettyjson.Marshal(e) }
+
+	// ConsoleEncoder encodes events as a single line of plain text, e.g.
+	//
+	//	2020-12-10T11:16:39.156Z ERROR unexpected error trace_id=abc123 error="something went wrong"
+	ConsoleEncoder Encoder = encodeConsole
+)
+
+// encoders are the encoders which can be selected with the LOG_FORMAT
+// environment variable
+var encoders = map[string]Encoder{
+	"json":       JSONEncoder,
+	"human":      HumanEncoder,
+	"console":    ConsoleEncoder,
+	"ecs":        ECSEncoder,
+	"ecs-compat": ECSCompatibleEncoder,
+	"gcp":        GCPEncoder(""),
+	"cloudwatch": CloudWatchEncoder(CloudWatchOptions{}),
+	"emf":        CloudWatchEncoder(CloudWatchOptions{EMF: true}),
+	"azure":      AzureEncoder,
+}
+
+// SetEncoder sets the encoder used for the destination set by SetDestination,
+// and should be called on startup before any events are logged. Pass nil to
+// restore the default encoder.
+//
+// The encoder can also be set using the LOG_FORMAT environment variable, e.g.
+// LOG_FORMAT=ecs, if HUMAN_LOG isn't set. See encoders for the valid values.
+func SetEncoder(enc Encoder) {
+	if enc == nil {
+		styler = initStyler()
+		return
+	}
+	styler = encoderStyler(enc)
+}
+
+// encoderStyler returns a styler which encodes events with enc
+func encoderStyler(enc Encoder) *styleFunc {
+	return &styleFunc{func(ctx context.Context, e EventData, ef eventFunc) []byte {
+		b, err := enc(e)
+
+		return handleStyleError(ctx, e, ef, b, err)
+	}}
+}
+
+// name returns the upper case name of the severity, e.g. "ERROR"
+func (s severity) name() string {
+	switch s {
+	case FATAL:
+		return "FATAL"
+	case ERROR:
+		return "ERROR"
+	case WARN:
+		return "WARN"
+	case INFO:
+		return "INFO"
+	case DEBUG:
+		return "DEBUG"
+	case TRACE:
+		return "TRACE"
+	}
+	return fmt.Sprintf("SEVERITY(%d)", int(s))
+}
+
+// encodeConsole implements ConsoleEncoder
+func encodeConsole(e EventData) ([]byte, error) {
+	var sb strings.Builder
+
+	sb.WriteString(e.CreatedAt.UTC().Format(time.RFC3339Nano))
+	if e.Severity != nil {
+		sb.WriteString(" " + e.Severity.name())
+	}
+	sb.WriteString(" " + e.Event)
+
+	field := func(k string, v interface{}) {
+		s := fmt.Sprint(v)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+		sb.WriteString(" " + k + "=" + s)
+	}
+
+	if e.TraceID != "" {
+		field("trace_id", e.TraceID)
+	}
+	if e.HTTP != nil {
+		field("method", e.HTTP.Method)
+		field("path", e.HTTP.Path)
+		if e.HTTP.StatusCode != nil && *e.HTTP.StatusCode != 0 {
+			field("status", *e.HTTP.StatusCode)
+		}
+		if e.HTTP.Duration != nil {
+			field("duration", *e.HTTP.Duration)
+		}
+	}
+	if e.Auth != nil && e.Auth.Identity != "" {
+		field("identity", e.Auth.Identity)
+	}
+	if e.Data != nil {
+		keys := make([]string, 0, len(*e.Data))
+		for k := range *e.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := (*e.Data)[k]
+			if _, ok := v.(string); !ok {
+				// nested values are easier to read as JSON
+				if b, err := json.Marshal(v); err == nil {
+					v = string(b)
+				}
+			}
+			field(k, v)
+		}
+	}
+	if e.Errors != nil {
+		for _, err := range *e.Errors {
+			field("error", err.Message)
+		}
+	}
+
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConsoleEncoder(t *testing.T) {
+	Convey("ConsoleEncoder encodes an event as a single line", t, func() {
+		sev := WARN
+		status := 404
+		e := EventData{
+			CreatedAt: time.Date(2020, 12, 10, 11, 16, 39, 0, time.UTC),
+			Event:     "not found",
+			TraceID:   "abc123",
+			Severity:  &sev,
+			HTTP:      &EventHTTP{Method: "GET", Path: "/foo", StatusCode: &status},
+			Data:      &Data{"b": "two words", "a": 1, "c": map[string]int{"x": 1}},
+			Errors:    FormatErrors([]error{errors.New("missing")}).(*EventErrors),
+		}
+
+		b, err := ConsoleEncoder(e)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, `2020-12-10T11:16:39Z WARN not found trace_id=abc123 method=GET path=/foo status=404 a=1 b="two words" c="{\"x\":1}" error=missing`)
+	})
+
+	Convey("Severities have names", t, func() {
+		So(FATAL.name(), ShouldEqual, "FATAL")
+		So(TRACE.name(), ShouldEqual, "TRACE")
+		So(severity(9).name(), ShouldEqual, "SEVERITY(9)")
+	})
+}
+
+func TestSetEncoder(t *testing.T) {
+	oldStyler := styler
+	defer func() {
+		styler = oldStyler
+	}()
+
+	Convey("SetEncoder sets the encoder for the destination", t, func() {
+		SetEncoder(func(e EventData) ([]byte, error) { return []byte("encoded " + e.Event), nil })
+		So(string(styler.f(nil, EventData{Event: "test"}, eventFunc{})), ShouldEqual, "encoded test")
+
+		Convey("And nil restores the default", func() {
+			t.Setenv("HUMAN_LOG", "")
+			t.Setenv("LOG_FORMAT", "")
+			SetEncoder(nil)
+			So(styler, ShouldEqual, styleForMachineFunc)
+		})
+	})
+
+	Convey("The LOG_FORMAT environment variable selects an encoder", t, func() {
+		t.Setenv("HUMAN_LOG", "")
+		t.Setenv("LOG_FORMAT", "ecs")
+
+		b := initStyler().f(nil, EventData{Event: "test"}, eventFunc{})
+		So(string(b), ShouldStartWith, `{"@timestamp":`)
+	})
+}
@@ -1,8 +1,10 @@
 package log
 
 import (
+	"fmt"
 	"reflect"
 	"runtime"
+	"strings"
 )
 
 // EventErrors is an array of error events
@@ -35,6 +37,16 @@ func (l *EventErrors) attach(le *EventData) {
 	le.Errors = l
 }
 
+// formatStackTrace formats a stack trace as text, in the same format as
+// a Go panic, for output formats which expect a string
+func formatStackTrace(frames []EventStackTrace) string {
+	var sb strings.Builder
+	for _, f := range frames {
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+	return sb.String()
+}
+
 // FormatErrors returns an option you can pass to Event to attach
 // error information to a log event
 //
@@ -0,0 +1,352 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeFormat is the format of the timestamp added to rotated files
+const backupTimeFormat = "20060102T150405.000000000"
+
+// rotateRetryInterval is how long a FileWriter waits to rotate the file again
+// after rotating it fails
+const rotateRetryInterval = time.Minute
+
+// FileOptions configures the rotation of a FileWriter
+type FileOptions struct {
+	// MaxSize is the size in bytes at which the file is rotated.
+	// The file isn't rotated by size if it is zero.
+	MaxSize int64
+
+	// RotateEvery is the interval at which the file is rotated, e.g.
+	// 24 * time.Hour. The file isn't rotated by time if it is zero.
+	RotateEvery time.Duration
+
+	// MaxBackups is the number of rotated files to keep. All rotated
+	// files are kept if it is zero.
+	MaxBackups int
+
+	// Compress gzips rotated files
+	Compress bool
+
+	// OnError is called with the error if the file can't be rotated before a
+	// write. The write goes to the current file instead, and rotation isn't
+	// tried again for a minute. It mustn't log if the FileWriter is the
+	// destination.
+	OnError func(err error)
+}
+
+// FileWriter is an io.Writer which writes to a file, and rotates it by size
+// and/or time. It can be used as a destination with SetDestination:
+//
+//	fw, err := log.NewFileWriter("/var/log/app/app.log", log.FileOptions{
+//		MaxSize:    100 << 20,
+//		MaxBackups: 5,
+//		Compress:   true,
+//	})
+//	if err != nil {
+//		...
+//	}
+//	defer fw.Close()
+//	log.SetDestination(fw, nil)
+//
+// Rotated files are renamed with a timestamp suffix, e.g.
+// app.log.20201210T111639.156400000.gz
+//
+// It is safe for concurrent use.
+type FileWriter struct {
+	path string
+	opts FileOptions
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	// retryRotate is when to try rotating again after rotating fails
+	retryRotate time.Time
+
+	// wg tracks compression running in the background
+	wg sync.WaitGroup
+	// cleanupMu stops backups being removed while they are compressed
+	cleanupMu sync.Mutex
+}
+
+// NewFileWriter opens (or creates) the file at path for appending, and
+// returns a FileWriter which rotates it using opts
+func NewFileWriter(path string, opts FileOptions) (*FileWriter, error) {
+	f := &FileWriter{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file, and must be called with f.mu held
+func (f *FileWriter) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.opts.RotateEvery > 0 {
+		f.nextRotate = time.Now().Add(f.opts.RotateEvery)
+	}
+
+	return nil
+}
+
+// Write writes p to the file, rotating it first if it is due to be rotated
+func (f *FileWriter) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.dueForRotation(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// keep writing to the current file rather than failing every write
+			f.retryRotate = time.Now().Add(rotateRetryInterval)
+			if f.opts.OnError != nil {
+				f.opts.OnError(err)
+			}
+		}
+	}
+
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// dueForRotation returns true if writing n bytes should rotate the file first
+func (f *FileWriter) dueForRotation(n int64) bool {
+	if time.Now().Before(f.retryRotate) {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size > 0 && f.size+n > f.opts.MaxSize {
+		return true
+	}
+	return f.opts.RotateEvery > 0 && !time.Now().Before(f.nextRotate)
+}
+
+// Rotate closes the file, renames it with a timestamp suffix, and opens a
+// new file. The rotated file is compressed and old backups are removed in
+// the background.
+func (f *FileWriter) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// rotate must be called with f.mu held
+func (f *FileWriter) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	backup := f.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(f.path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		// carry on writing to the current file rather than leaving the
+		// writer closed
+		return errors.Join(err, f.open())
+	}
+
+	if err := f.open(); err != nil {
+		// put the current file back so the writer can still be used
+		if os.Rename(backup, f.path) == nil {
+			return errors.Join(err, f.open())
+		}
+		return err
+	}
+	f.retryRotate = time.Time{}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.cleanupMu.Lock()
+		defer f.cleanupMu.Unlock()
+
+		if f.opts.Compress {
+			compressFile(backup) //nolint:errcheck // the uncompressed backup is kept if compression fails
+		}
+		f.removeOldBackups() //nolint:errcheck // removal is retried on the next rotation
+	}()
+
+	return nil
+}
+
+// Reopen closes and reopens the file, for use when the file has been moved
+// by something else, e.g. logrotate without copytruncate
+func (f *FileWriter) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	return f.open()
+}
+
+// ReopenOnSignal calls Reopen whenever the process receives one of the
+// signals, or SIGHUP if none are given. It returns a function which stops
+// listening for the signals.
+func (f *FileWriter) ReopenOnSignal(sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sig...)
+
+	go func() {
+		for {
+			select {
+			case <-c:
+				if err := f.Reopen(); err != nil {
+					// if this is the destination it may now be unusable, in
+					// which case this is written to the fallback destination
+					//nolint:staticcheck // Passing nil context here is intentional
+					Error(nil, "failed to reopen log file", err, Data{"path": f.path})
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// Close closes the file, and waits for any background compression to finish
+func (f *FileWriter) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+	return err
+}
+
+// backups returns the paths of rotated files, oldest first
+func (f *FileWriter) backups() ([]string, error) {
+	dir, base := filepath.Split(f.path)
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), base+".")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, strings.TrimSuffix(suffix, ".gz")); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, e.Name()))
+	}
+
+	// the timestamp format sorts in time order
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// removeOldBackups removes the oldest rotated files beyond MaxBackups
+func (f *FileWriter) removeOldBackups() error {
+	if f.opts.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > f.opts.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// compressFile gzips the file at path to path.gz, and removes the original
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,228 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func readFile(path string) string {
+	b, _ := os.ReadFile(path)
+	return string(b)
+}
+
+func readGzipFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return ""
+	}
+	b, _ := io.ReadAll(gz)
+	return string(b)
+}
+
+func TestFileWriter(t *testing.T) {
+	Convey("Given a FileWriter", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+
+		Convey("Writes are appended to the file", func() {
+			So(os.WriteFile(path, []byte("existing\n"), 0o644), ShouldBeNil)
+			fw, err := NewFileWriter(path, FileOptions{})
+			So(err, ShouldBeNil)
+
+			fw.Write([]byte("line 1\n"))
+			So(fw.Close(), ShouldBeNil)
+			So(readFile(path), ShouldEqual, "existing\nline 1\n")
+
+			Convey("And writes fail after Close", func() {
+				_, err := fw.Write([]byte("line 2\n"))
+				So(err, ShouldEqual, os.ErrClosed)
+			})
+		})
+
+		Convey("The file is rotated when it reaches MaxSize", func() {
+			fw, err := NewFileWriter(path, FileOptions{MaxSize: 10})
+			So(err, ShouldBeNil)
+
+			fw.Write([]byte("12345678\n"))
+			fw.Write([]byte("abcdefgh\n"))
+			So(fw.Close(), ShouldBeNil)
+
+			So(readFile(path), ShouldEqual, "abcdefgh\n")
+			backups, err := fw.backups()
+			So(err, ShouldBeNil)
+			So(backups, ShouldHaveLength, 1)
+			So(readFile(backups[0]), ShouldEqual, "12345678\n")
+		})
+
+		Convey("A single write larger than MaxSize isn't rotated into an empty file", func() {
+			fw, err := NewFileWriter(path, FileOptions{MaxSize: 4})
+			So(err, ShouldBeNil)
+			fw.Write([]byte("123456789\n"))
+			So(fw.Close(), ShouldBeNil)
+
+			backups, _ := fw.backups()
+			So(backups, ShouldBeEmpty)
+			So(readFile(path), ShouldEqual, "123456789\n")
+		})
+
+		Convey("The file is rotated after RotateEvery", func() {
+			fw, err := NewFileWriter(path, FileOptions{RotateEvery: 20 * time.Millisecond})
+			So(err, ShouldBeNil)
+
+			fw.Write([]byte("before\n"))
+			time.Sleep(30 * time.Millisecond)
+			fw.Write([]byte("after\n"))
+			So(fw.Close(), ShouldBeNil)
+
+			So(readFile(path), ShouldEqual, "after\n")
+			backups, _ := fw.backups()
+			So(backups, ShouldHaveLength, 1)
+			So(readFile(backups[0]), ShouldEqual, "before\n")
+		})
+
+		Convey("Rotated files are compressed and old backups removed", func() {
+			fw, err := NewFileWriter(path, FileOptions{MaxBackups: 2, Compress: true})
+			So(err, ShouldBeNil)
+
+			for _, line := range []string{"one\n", "two\n", "three\n"} {
+				fw.Write([]byte(line))
+				So(fw.Rotate(), ShouldBeNil)
+				// wait for the background compression, so each rotation is cleaned up in order
+				fw.wg.Wait()
+			}
+			So(fw.Close(), ShouldBeNil)
+
+			backups, _ := fw.backups()
+			So(backups, ShouldHaveLength, 2)
+			So(strings.HasSuffix(backups[0], ".gz"), ShouldBeTrue)
+			So(readGzipFile(backups[0]), ShouldEqual, "two\n")
+			So(readGzipFile(backups[1]), ShouldEqual, "three\n")
+		})
+
+		Convey("Unrelated files aren't treated as backups", func() {
+			So(os.WriteFile(filepath.Join(dir, "app.log.old"), []byte("x"), 0o644), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(dir, "other.log.20200101T000000.000000000"), []byte("x"), 0o644), ShouldBeNil)
+			fw, err := NewFileWriter(path, FileOptions{})
+			So(err, ShouldBeNil)
+			defer fw.Close()
+
+			backups, _ := fw.backups()
+			So(backups, ShouldBeEmpty)
+		})
+
+		Convey("The file is still written to if it can't be rotated", func() {
+			// the name is too long to add the backup suffix to
+			path := filepath.Join(dir, strings.Repeat("a", 240))
+			fw, err := NewFileWriter(path, FileOptions{})
+			So(err, ShouldBeNil)
+			defer fw.Close()
+
+			fw.Write([]byte("before\n"))
+			So(fw.Rotate(), ShouldNotBeNil)
+
+			_, err = fw.Write([]byte("after\n"))
+			So(err, ShouldBeNil)
+			So(readFile(path), ShouldEqual, "before\nafter\n")
+		})
+
+		Convey("Writes go to the current file if it can't be rotated when it reaches MaxSize", func() {
+			var rotateErrors []error
+			path := filepath.Join(dir, strings.Repeat("a", 240))
+			fw, err := NewFileWriter(path, FileOptions{
+				MaxSize: 10,
+				OnError: func(err error) { rotateErrors = append(rotateErrors, err) },
+			})
+			So(err, ShouldBeNil)
+			defer fw.Close()
+
+			for _, line := range []string{"line 1\n", "line 2\n", "line 3\n"} {
+				_, err := fw.Write([]byte(line))
+				So(err, ShouldBeNil)
+			}
+
+			So(readFile(path), ShouldEqual, "line 1\nline 2\nline 3\n")
+			So(rotateErrors, ShouldHaveLength, 1)
+		})
+
+		Convey("Reopen opens a new file after the file has been moved", func() {
+			fw, err := NewFileWriter(path, FileOptions{})
+			So(err, ShouldBeNil)
+
+			fw.Write([]byte("before\n"))
+			So(os.Rename(path, path+".moved"), ShouldBeNil)
+			So(fw.Reopen(), ShouldBeNil)
+			fw.Write([]byte("after\n"))
+			So(fw.Close(), ShouldBeNil)
+
+			So(readFile(path+".moved"), ShouldEqual, "before\n")
+			So(readFile(path), ShouldEqual, "after\n")
+		})
+
+		Convey("ReopenOnSignal reopens the file on SIGHUP", func() {
+			fw, err := NewFileWriter(path, FileOptions{})
+			So(err, ShouldBeNil)
+			stop := fw.ReopenOnSignal()
+			defer stop()
+
+			So(os.Rename(path, path+".moved"), ShouldBeNil)
+			p, err := os.FindProcess(os.Getpid())
+			So(err, ShouldBeNil)
+			So(p.Signal(syscall.SIGHUP), ShouldBeNil)
+
+			deadline := time.Now().Add(time.Second)
+			for time.Now().Before(deadline) {
+				if _, err := os.Stat(path); err == nil {
+					break
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			_, err = os.Stat(path)
+			So(err, ShouldBeNil)
+			So(fw.Close(), ShouldBeNil)
+		})
+
+		Convey("Concurrent writes are safe", func() {
+			fw, err := NewFileWriter(path, FileOptions{MaxSize: 100, MaxBackups: 100})
+			So(err, ShouldBeNil)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						fw.Write([]byte("0123456789\n"))
+					}
+				}()
+			}
+			wg.Wait()
+			So(fw.Close(), ShouldBeNil)
+
+			total := len(readFile(path))
+			backups, _ := fw.backups()
+			for _, b := range backups {
+				total += len(readFile(b))
+			}
+			So(total, ShouldEqual, 10*20*11)
+		})
+	})
+
+	Convey("NewFileWriter returns an error if the file can't be opened", t, func() {
+		_, err := NewFileWriter(filepath.Join(t.TempDir(), "missing", "app.log"), FileOptions{})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,258 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultFlightRecorderSize is the number of events kept by the flight
+// recorder, if FlightRecorderOptions.Size isn't set
+const DefaultFlightRecorderSize = 1000
+
+// FlightRecorderOptions configures the flight recorder
+type FlightRecorderOptions struct {
+	// Size is the number of recent events to keep
+	Size int
+
+	// DumpPath is a file which dumps are appended to. Dumps are written to
+	// stderr if it's empty.
+	DumpPath string
+
+	// DumpSignals are the signals which cause a dump, which default to
+	// SIGUSR1 on platforms which support it. Set it to an empty slice to
+	// disable dumps on a signal.
+	DumpSignals []os.Signal
+}
+
+// FlightRecorder keeps the most recent events in memory, at all severities
+// including those below the minimum severity, so they can be dumped when a
+// process crashes. See StartFlightRecorder.
+type FlightRecorder struct {
+	opts FlightRecorderOptions
+
+	mu     sync.Mutex
+	events []EventData
+	next   int
+	full   bool
+
+	dumpMu  sync.Mutex
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+// flightRecorder is the active flight recorder, or nil
+var flightRecorder atomic.Pointer[FlightRecorder]
+
+// StartFlightRecorder starts recording events, replacing any flight recorder
+// which has already been started. Recorded events are dumped as JSON lines:
+//
+//   - after a FATAL event is logged
+//   - when a panic is recovered by DumpOnPanic
+//   - when the process receives one of the dump signals, SIGUSR1 by default
+//
+// They can also be viewed using FlightRecorderHandler.
+func StartFlightRecorder(opts FlightRecorderOptions) *FlightRecorder {
+	if opts.Size <= 0 {
+		opts.Size = DefaultFlightRecorderSize
+	}
+	if opts.DumpSignals == nil {
+		opts.DumpSignals = defaultDumpSignals
+	}
+
+	r := &FlightRecorder{
+		opts:    opts,
+		events:  make([]EventData, opts.Size),
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+	}
+
+	if len(opts.DumpSignals) > 0 {
+		signal.Notify(r.signals, opts.DumpSignals...)
+	}
+	go r.handleSignals()
+
+	if old := flightRecorder.Swap(r); old != nil {
+		old.stop()
+	}
+	return r
+}
+
+// Stop stops recording events
+func (r *FlightRecorder) Stop() {
+	if flightRecorder.CompareAndSwap(r, nil) {
+		r.stop()
+	}
+}
+
+func (r *FlightRecorder) stop() {
+	signal.Stop(r.signals)
+	close(r.done)
+}
+
+func (r *FlightRecorder) handleSignals() {
+	for {
+		select {
+		case <-r.signals:
+			_ = r.Dump()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// add records an event, overwriting the oldest event if the recorder is full
+func (r *FlightRecorder) add(e EventData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events[r.next] = e
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Events returns the recorded events, oldest first
+func (r *FlightRecorder) Events() []EventData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]EventData(nil), r.events[:r.next]...)
+	}
+	return append(append([]EventData(nil), r.events[r.next:]...), r.events[:r.next]...)
+}
+
+// Dump writes the recorded events to the dump file, or stderr
+func (r *FlightRecorder) Dump() error {
+	r.dumpMu.Lock()
+	defer r.dumpMu.Unlock()
+
+	if r.opts.DumpPath == "" {
+		return r.WriteEvents(os.Stderr)
+	}
+
+	f, err := os.OpenFile(r.opts.DumpPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteEvents(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteEvents writes the recorded events to w as JSON lines
+func (r *FlightRecorder) WriteEvents(w io.Writer) error {
+	return writeEvents(w, r.Events())
+}
+
+func writeEvents(w io.Writer, events []EventData) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// recordEvent adds a snapshot of an event to the active flight recorder, if
+// there is one
+func recordEvent(e *EventData) {
+	if r := flightRecorder.Load(); r != nil {
+		r.add(*e.snapshot())
+	}
+}
+
+// dumpFlightRecorder dumps the active flight recorder, if there is one
+func dumpFlightRecorder() {
+	if r := flightRecorder.Load(); r != nil {
+		_ = r.Dump()
+	}
+}
+
+// DumpOnPanic dumps the active flight recorder if the goroutine is
+// panicking, and then continues to panic. It must be deferred directly:
+//
+//	func main() {
+//		log.StartFlightRecorder(log.FlightRecorderOptions{})
+//		defer log.DumpOnPanic()
+//		...
+//	}
+//
+// The panic is recovered and panicked again, so the stack trace printed when
+// the process exits starts at DumpOnPanic. The original stack trace is kept
+// in a "panic" event at the end of the dump.
+func DumpOnPanic() {
+	if v := recover(); v != nil {
+		if r := flightRecorder.Load(); r != nil {
+			r.add(*createEvent(context.Background(), "panic", FATAL, Data{
+				"panic": fmt.Sprint(v),
+				"stack": string(debug.Stack()),
+			}))
+			_ = r.Dump()
+		}
+		panic(v)
+	}
+}
+
+// FlightRecorderHandler returns an http.Handler which responds with the
+// events recorded by the active flight recorder as JSON lines. The optional
+// severity query parameter limits the events to those at least as severe,
+// e.g. ?severity=warn, and limit returns only the most recent events.
+//
+// The events can contain sensitive data, so it should only be available on
+// an internal port.
+func FlightRecorderHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r := flightRecorder.Load()
+		if r == nil {
+			http.Error(w, "flight recorder is not started", http.StatusNotFound)
+			return
+		}
+
+		events := r.Events()
+
+		if s := req.URL.Query().Get("severity"); s != "" {
+			threshold, ok := parseSeverity(s)
+			if !ok {
+				http.Error(w, "invalid severity", http.StatusBadRequest)
+				return
+			}
+			filtered := events[:0]
+			for _, e := range events {
+				if e.Severity != nil && *e.Severity <= threshold {
+					filtered = append(filtered, e)
+				}
+			}
+			events = filtered
+		}
+
+		if l := req.URL.Query().Get("limit"); l != "" {
+			limit, err := strconv.Atoi(l)
+			if err != nil || limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			if limit < len(events) {
+				events = events[len(events)-limit:]
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_ = writeEvents(w, events)
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultDumpSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,7 @@
+package log
+
+import "os"
+
+// SIGUSR1 isn't available on Windows, so dumps aren't triggered by a signal
+// unless FlightRecorderOptions.DumpSignals is set
+var defaultDumpSignals []os.Signal
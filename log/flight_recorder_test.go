@@ -0,0 +1,142 @@
+//go:build !windows
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+
+	Convey("Given a flight recorder", t, func() {
+		destination = io.Discard
+		dumpPath := filepath.Join(t.TempDir(), "dump.log")
+		r := StartFlightRecorder(FlightRecorderOptions{Size: 3, DumpPath: dumpPath})
+		defer r.Stop()
+
+		dumped := func() []string {
+			b, _ := os.ReadFile(dumpPath)
+			return eventNames(loggedEvents(bytes.NewBuffer(b)))
+		}
+
+		Convey("Events at all severities are recorded", func() {
+			Info(context.Background(), "info")
+			Trace(context.Background(), "trace")
+
+			So(eventNamesOf(r.Events()), ShouldResemble, []string{"info", "trace"})
+
+			Convey("And the oldest are overwritten when it's full", func() {
+				Warn(context.Background(), "warn")
+				Debug(context.Background(), "debug")
+
+				So(eventNamesOf(r.Events()), ShouldResemble, []string{"trace", "warn", "debug"})
+			})
+		})
+
+		Convey("Recorded events aren't changed if the caller reuses their data", func() {
+			data := Data{"key": "before"}
+			Info(context.Background(), "info", data)
+			data["key"] = "after"
+
+			events := r.Events()
+			So(events, ShouldHaveLength, 1)
+			So(*events[0].Data, ShouldResemble, Data{"key": "before"})
+		})
+
+		Convey("Events are dumped after a FATAL event", func() {
+			Debug(context.Background(), "debug")
+			So(dumped(), ShouldBeEmpty)
+
+			Fatal(context.Background(), "fatal", nil)
+			So(dumped(), ShouldResemble, []string{"debug", "fatal"})
+		})
+
+		Convey("Events are dumped by DumpOnPanic", func() {
+			Info(context.Background(), "before panic")
+
+			So(func() {
+				defer DumpOnPanic()
+				panic("test")
+			}, ShouldPanicWith, "test")
+			So(dumped(), ShouldResemble, []string{"before panic", "panic"})
+
+			Convey("With the stack trace of the panic", func() {
+				b, _ := os.ReadFile(dumpPath)
+				events := loggedEvents(bytes.NewBuffer(b))
+				data := events[1]["data"].(map[string]interface{})
+				So(data["panic"], ShouldEqual, "test")
+				So(data["stack"], ShouldContainSubstring, "flight_recorder_test.go")
+			})
+		})
+
+		Convey("Events are dumped when the process receives SIGUSR1", func() {
+			Info(context.Background(), "before signal")
+
+			p, err := os.FindProcess(os.Getpid())
+			So(err, ShouldBeNil)
+			So(p.Signal(syscall.SIGUSR1), ShouldBeNil)
+
+			So(waitFor(func() bool { return len(dumped()) == 1 }), ShouldBeTrue)
+		})
+
+		Convey("Events are not recorded after it is stopped", func() {
+			r.Stop()
+			Info(context.Background(), "after stop")
+			So(r.Events(), ShouldBeEmpty)
+		})
+
+		Convey("FlightRecorderHandler returns the recorded events", func() {
+			Info(context.Background(), "info")
+			Warn(context.Background(), "warn")
+			Error(context.Background(), "error", nil)
+
+			get := func(target string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				FlightRecorderHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+				return w
+			}
+
+			w := get("/debug/log")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+			So(eventNames(loggedEvents(w.Body)), ShouldResemble, []string{"info", "warn", "error"})
+
+			So(eventNames(loggedEvents(get("/debug/log?severity=warn").Body)), ShouldResemble, []string{"warn", "error"})
+			So(eventNames(loggedEvents(get("/debug/log?limit=1").Body)), ShouldResemble, []string{"error"})
+			So(get("/debug/log?severity=loud").Code, ShouldEqual, http.StatusBadRequest)
+
+			Convey("Or 404 if the flight recorder is stopped", func() {
+				r.Stop()
+				So(get("/debug/log").Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func eventNamesOf(events []EventData) []string {
+	names := make([]string, 0, len(events))
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	return names
+}
@@ -0,0 +1,144 @@
+package log
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// gcpErrorReportingType makes Error Reporting group events with errors
+const gcpErrorReportingType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// GCPEncoder returns an encoder for Google Cloud Logging structured JSON.
+//
+// The severity is a Cloud Logging severity string, HTTP events include an
+// httpRequest, and ERROR and FATAL events with errors are reported to Error
+// Reporting. The trace ID is only linked to Cloud Trace if it is a W3C trace
+// ID, rather than a request ID, and the project ID is set, which defaults to
+// the GOOGLE_CLOUD_PROJECT environment variable.
+func GCPEncoder(projectID string) Encoder {
+	if projectID == "" {
+		projectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	return func(e EventData) ([]byte, error) {
+		return json.Marshal(newGCPEvent(e, projectID))
+	}
+}
+
+// gcpEvent is an event in Cloud Logging's structured JSON format. Fields
+// which Cloud Logging doesn't recognise are included in the jsonPayload.
+type gcpEvent struct {
+	Type           string             `json:"@type,omitempty"`
+	Time           string             `json:"time"`
+	Severity       string             `json:"severity"`
+	Message        string             `json:"message"`
+	Trace          string             `json:"logging.googleapis.com/trace,omitempty"`
+	SpanID         string             `json:"logging.googleapis.com/spanId,omitempty"`
+	HTTPRequest    *gcpHTTPRequest    `json:"httpRequest,omitempty"`
+	ServiceContext *gcpServiceContext `json:"serviceContext,omitempty"`
+	StackTrace     string             `json:"stack_trace,omitempty"`
+
+	Namespace string       `json:"namespace"`
+	TraceID   string       `json:"trace_id,omitempty"`
+	Buffered  bool         `json:"buffered,omitempty"`
+	GRPC      *EventGRPC   `json:"grpc,omitempty"`
+	Auth      *EventAuth   `json:"auth,omitempty"`
+	Data      *Data        `json:"data,omitempty"`
+	Errors    *EventErrors `json:"errors,omitempty"`
+}
+
+type gcpHTTPRequest struct {
+	RequestMethod string `json:"requestMethod,omitempty"`
+	RequestURL    string `json:"requestUrl,omitempty"`
+	Status        int    `json:"status,omitempty"`
+	ResponseSize  string `json:"responseSize,omitempty"`
+	Latency       string `json:"latency,omitempty"`
+}
+
+type gcpServiceContext struct {
+	Service string `json:"service"`
+}
+
+// gcpSeverity maps a severity to a Cloud Logging severity
+func gcpSeverity(s *severity) string {
+	if s == nil {
+		return "DEFAULT"
+	}
+	switch *s {
+	case FATAL:
+		return "CRITICAL"
+	case ERROR:
+		return "ERROR"
+	case WARN:
+		return "WARNING"
+	case INFO:
+		return "INFO"
+	}
+	return "DEBUG"
+}
+
+func newGCPEvent(e EventData, projectID string) gcpEvent {
+	g := gcpEvent{
+		Time:      e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Severity:  gcpSeverity(e.Severity),
+		Message:   e.Event,
+		Namespace: e.Namespace,
+		TraceID:   e.TraceID,
+		Buffered:  e.Buffered,
+		GRPC:      e.GRPC,
+		Auth:      e.Auth,
+		Data:      e.Data,
+		Errors:    e.Errors,
+	}
+
+	if traceID, err := trace.TraceIDFromHex(e.TraceID); err == nil && projectID != "" {
+		g.Trace = "projects/" + projectID + "/traces/" + traceID.String()
+		if spanID, err := trace.SpanIDFromHex(e.SpanID); err == nil {
+			g.SpanID = spanID.String()
+		}
+	}
+
+	if e.HTTP != nil {
+		g.HTTPRequest = &gcpHTTPRequest{
+			RequestMethod: e.HTTP.Method,
+			RequestURL:    httpURL(e.HTTP),
+		}
+		if e.HTTP.StatusCode != nil {
+			g.HTTPRequest.Status = *e.HTTP.StatusCode
+		}
+		if e.HTTP.ResponseContentLength > 0 {
+			g.HTTPRequest.ResponseSize = strconv.FormatInt(e.HTTP.ResponseContentLength, 10)
+		}
+		if e.HTTP.Duration != nil {
+			g.HTTPRequest.Latency = strconv.FormatFloat(e.HTTP.Duration.Seconds(), 'f', -1, 64) + "s"
+		}
+	}
+
+	if e.Severity != nil && *e.Severity <= ERROR && e.Errors != nil && len(*e.Errors) > 0 {
+		// Error Reporting expects a stack trace in the format of a Go panic
+		err := (*e.Errors)[0]
+		g.Type = gcpErrorReportingType
+		g.ServiceContext = &gcpServiceContext{Service: e.Namespace}
+		g.StackTrace = err.Message + "\n\ngoroutine 1 [running]:\n" + formatStackTrace(err.StackTrace)
+	}
+
+	return g
+}
+
+// httpURL returns the URL of an HTTP event
+func httpURL(h *EventHTTP) string {
+	u := url.URL{
+		Scheme:   h.Scheme,
+		Host:     h.Host,
+		Path:     h.Path,
+		RawQuery: h.Query,
+	}
+	if h.Port != 0 && h.Host != "" {
+		u.Host = h.Host + ":" + strconv.Itoa(h.Port)
+	}
+	return u.String()
+}
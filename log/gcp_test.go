@@ -0,0 +1,100 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGCPEncoder(t *testing.T) {
+	Convey("Given an HTTP event with an error", t, func() {
+		sev := ERROR
+		status := 500
+		duration := 1500 * time.Millisecond
+		e := EventData{
+			CreatedAt: time.Date(2020, 12, 10, 11, 16, 39, 156000000, time.UTC),
+			Namespace: "my-service",
+			Event:     "request failed",
+			TraceID:   "0102030405060708090a0b0c0d0e0f10",
+			SpanID:    "0102030405060708",
+			Severity:  &sev,
+			HTTP: &EventHTTP{Method: "GET", Scheme: "https", Host: "example.com", Port: 8443, Path: "/foo", Query: "a=1",
+				StatusCode: &status, Duration: &duration, ResponseContentLength: 42},
+			Data:   &Data{"key": "value"},
+			Errors: FormatErrors([]error{errors.New("test error")}).(*EventErrors),
+		}
+
+		Convey("GCPEncoder encodes it as Cloud Logging structured JSON", func() {
+			b, err := GCPEncoder("my-project")(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["severity"], ShouldEqual, "ERROR")
+			So(m["message"], ShouldEqual, "request failed")
+			So(m["time"], ShouldEqual, "2020-12-10T11:16:39.156Z")
+			So(m["logging.googleapis.com/trace"], ShouldEqual, "projects/my-project/traces/0102030405060708090a0b0c0d0e0f10")
+			So(m["logging.googleapis.com/spanId"], ShouldEqual, "0102030405060708")
+			So(m["httpRequest"], ShouldResemble, map[string]interface{}{
+				"requestMethod": "GET",
+				"requestUrl":    "https://example.com:8443/foo?a=1",
+				"status":        float64(500),
+				"responseSize":  "42",
+				"latency":       "1.5s",
+			})
+			So(m["data"], ShouldResemble, map[string]interface{}{"key": "value"})
+			So(m, ShouldNotContainKey, "buffered")
+
+			Convey("And reports the error to Error Reporting", func() {
+				So(m["@type"], ShouldEqual, gcpErrorReportingType)
+				So(m["serviceContext"], ShouldResemble, map[string]interface{}{"service": "my-service"})
+				So(m["stack_trace"], ShouldStartWith, "test error\n\ngoroutine 1 [running]:\n")
+			})
+		})
+
+		Convey("Events from a debug buffer are marked as buffered", func() {
+			e.Buffered = true
+			b, err := GCPEncoder("my-project")(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m["buffered"], ShouldBeTrue)
+		})
+
+		Convey("A request ID isn't linked as a trace", func() {
+			e.TraceID = "abc123"
+			b, err := GCPEncoder("my-project")(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m, ShouldNotContainKey, "logging.googleapis.com/trace")
+			So(m, ShouldNotContainKey, "logging.googleapis.com/spanId")
+			So(m["trace_id"], ShouldEqual, "abc123")
+		})
+
+		Convey("The trace isn't linked without a project ID", func() {
+			t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+
+			b, err := GCPEncoder("")(e)
+			So(err, ShouldBeNil)
+
+			var m map[string]interface{}
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+			So(m, ShouldNotContainKey, "logging.googleapis.com/trace")
+			So(m["trace_id"], ShouldEqual, "0102030405060708090a0b0c0d0e0f10")
+		})
+	})
+
+	Convey("Severities are mapped to Cloud Logging severities", t, func() {
+		for s, name := range map[severity]string{FATAL: "CRITICAL", ERROR: "ERROR", WARN: "WARNING", INFO: "INFO", DEBUG: "DEBUG", TRACE: "DEBUG"} {
+			s := s
+			So(gcpSeverity(&s), ShouldEqual, name)
+		}
+		So(gcpSeverity(nil), ShouldEqual, "DEFAULT")
+	})
+}
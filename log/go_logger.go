@@ -1,21 +1,230 @@
 package log
 
 import (
+	"bytes"
+	"io"
 	"log"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 )
 
+// stdLogEvent is the default event name for logs captured from the
+// standard library logger
+const stdLogEvent = "third party logs"
+
 //nolint:gochecknoinits // The init function is necessary for setting up default logging.
 func init() {
-	// Set the output for the default go logger
+	// Capture the output of the default go logger, unless it has been
+	// disabled using the CAPTURE_STD_LOG environment variable
+	if v, err := strconv.ParseBool(os.Getenv("CAPTURE_STD_LOG")); err == nil && !v {
+		return
+	}
+	CaptureStdLog()
+}
+
+var stdLogMutex sync.Mutex
+var stdLogCaptured bool
+var stdLogOutput io.Writer
+var stdLogFlags int
+
+// CaptureStdLog sets the output of the default go logger (from the standard
+// library log package) so that each line is logged as an event.
+//
+// It is called automatically when this package is imported, unless the
+// CAPTURE_STD_LOG environment variable is set to a false value (false,
+// FALSE, 0). Use RestoreStdLog to undo it.
+func CaptureStdLog() {
+	stdLogMutex.Lock()
+	defer stdLogMutex.Unlock()
+
+	if stdLogCaptured {
+		return
+	}
+
+	stdLogOutput, stdLogFlags = log.Writer(), log.Flags()
+	stdLogCaptured = true
+
 	log.SetOutput(&captureLogger{})
-	log.SetFlags(log.Flags() &^ (log.Ldate | log.Ltime))
+	log.SetFlags(log.Flags()&^(log.Ldate|log.Ltime) | log.Lshortfile)
+}
+
+// RestoreStdLog restores the output and flags the default go logger had
+// before CaptureStdLog was called
+func RestoreStdLog() {
+	stdLogMutex.Lock()
+	defer stdLogMutex.Unlock()
+
+	if !stdLogCaptured {
+		return
+	}
+
+	log.SetOutput(stdLogOutput)
+	log.SetFlags(stdLogFlags)
+	stdLogCaptured = false
 }
 
-type captureLogger struct{}
+// Writer returns an io.WriteCloser which logs each line written to it as an
+// event with the given severity and event name, for example to capture the
+// output of an exec.Cmd:
+//
+//	stderr := log.Writer(log.WARN, "command output")
+//	cmd.Stderr = stderr
+//	err := cmd.Run()
+//	stderr.Close()
+//
+// A line which is split over multiple writes is logged once it is complete,
+// and Close logs any remaining incomplete line.
+func Writer(severity severity, event string) io.WriteCloser {
+	return &lineWriter{captureLogger: captureLogger{event: event, severity: &severity}}
+}
+
+// NewStdLogger returns a standard library *log.Logger which logs each line
+// written to it as an event, in the same way as the default go logger.
+//
+// This can be used for libraries which accept a *log.Logger, with an event
+// name and namespace to identify the source of the logs. An empty event
+// defaults to "third party logs", and an empty namespace defaults to the
+// Namespace variable.
+func NewStdLogger(event, namespace string) *log.Logger {
+	return log.New(&captureLogger{event: event, namespace: namespace}, "", log.Lshortfile)
+}
+
+// captureLogger is an io.Writer which converts the output of a standard
+// library logger to events
+type captureLogger struct {
+	event     string
+	namespace string
+
+	// severity is used for every line if set, otherwise the severity
+	// is detected from the content of each line
+	severity *severity
+}
+
+// callerPattern matches the file and line number added by log.Lshortfile
+// or log.Llongfile
+var callerPattern = regexp.MustCompile(`^(\S+\.go:\d+): `)
+
+// severityPatterns are used to detect the severity of a captured line,
+// and are checked in order
+var severityPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity severity
+}{
+	{regexp.MustCompile(`(?i)^(\[fatal\]|fatal:)|\blevel=fatal\b`), FATAL},
+	{regexp.MustCompile(`(?i)^(\[error\]|error:|\[err\]|panic:)|\blevel=error\b|\bhttp: panic serving\b`), ERROR},
+	{regexp.MustCompile(`(?i)^(\[warn\]|\[warning\]|warn:|warning:)|\blevel=warn(ing)?\b`), WARN},
+	{regexp.MustCompile(`(?i)^(\[debug\]|debug:)|\blevel=debug\b`), DEBUG},
+}
 
 func (c captureLogger) Write(b []byte) (n int, err error) {
+	for _, line := range splitCapturedLines(string(b)) {
+		c.logLine(line)
+	}
+	return len(b), nil
+}
+
+// logLine logs a single captured line (including any continuation lines)
+func (c captureLogger) logLine(line string) {
+	data := Data{}
+	if m := callerPattern.FindStringSubmatch(line); m != nil {
+		data["caller"] = m[1]
+		line = line[len(m[0]):]
+	}
+	data["raw"] = line
+
+	severity := INFO
+	if c.severity != nil {
+		severity = *c.severity
+	} else {
+		for _, p := range severityPatterns {
+			if p.pattern.MatchString(line) {
+				severity = p.severity
+				break
+			}
+		}
+	}
+
+	event := c.event
+	if event == "" {
+		event = stdLogEvent
+	}
+
+	if c.namespace != "" {
+		//nolint:staticcheck // Passing nil context here is intentional
+		Event(nil, event, severity, data, namespaceOption(c.namespace))
+		return
+	}
+
 	//nolint:staticcheck // Passing nil context here is intentional
-	Event(nil, "third party logs", INFO, Data{"raw": strings.TrimSpace(string(b))})
+	Event(nil, event, severity, data)
+}
+
+// splitCapturedLines splits a write into separate lines, keeping indented
+// lines and goroutine stack traces with the line before them
+func splitCapturedLines(s string) []string {
+	var lines []string
+	inStack := false
+	for _, l := range strings.Split(strings.TrimSpace(s), "\n") {
+		l = strings.TrimRight(l, "\r")
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+
+		if len(lines) > 0 && strings.HasPrefix(l, "goroutine ") {
+			inStack = true
+		}
+		if len(lines) > 0 && (inStack || l[0] == ' ' || l[0] == '\t') {
+			lines[len(lines)-1] += "\n" + l
+			continue
+		}
+
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// namespaceOption is an option which overrides the namespace of an event
+type namespaceOption string
+
+func (n namespaceOption) attach(le *EventData) {
+	le.Namespace = string(n)
+}
+
+// lineWriter is a captureLogger which buffers incomplete lines, for
+// writers which don't always write whole lines at a time
+type lineWriter struct {
+	captureLogger
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (w *lineWriter) Write(b []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, b...)
+	if i := bytes.LastIndexByte(w.partial, '\n'); i >= 0 {
+		complete := string(w.partial[:i+1])
+		w.partial = append(w.partial[:0], w.partial[i+1:]...)
+		w.captureLogger.Write([]byte(complete)) //nolint:errcheck // captureLogger never returns an error
+	}
+
 	return len(b), nil
 }
+
+// Close logs any remaining incomplete line
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.partial) > 0 {
+		w.captureLogger.Write(w.partial) //nolint:errcheck // captureLogger never returns an error
+		w.partial = nil
+	}
+
+	return nil
+}
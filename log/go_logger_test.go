@@ -2,6 +2,8 @@ package log
 
 import (
 	"log"
+	"os"
+	"os/exec"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -34,3 +36,161 @@ func TestGoLogger(t *testing.T) {
 		So(capData["raw"], ShouldEqual, "test")
 	})
 }
+
+func TestCaptureLogger(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("The caller is captured from the standard library logger", t, func() {
+		log.Println("test")
+
+		capData := mock.capOpts[0].(Data)
+		So(capData["caller"], ShouldStartWith, "go_logger_test.go:")
+		So(capData["raw"], ShouldEqual, "test")
+	})
+
+	Convey("The severity is detected from common prefixes", t, func() {
+		for line, expected := range map[string]severity{
+			"plain message":                    INFO,
+			"[ERROR] something failed":         ERROR,
+			"error: something failed":          ERROR,
+			"WARN: something odd":              WARN,
+			"[warning] something odd":          WARN,
+			"time=now level=error msg=failed":  ERROR,
+			"level=debug msg=details":          DEBUG,
+			"[DEBUG] details":                  DEBUG,
+			"FATAL: giving up":                 FATAL,
+			"panic: runtime error":             ERROR,
+			"http: panic serving 1.2.3.4: err": ERROR,
+			"an error occurred but no prefix":  INFO,
+		} {
+			captureLogger{}.Write([]byte(line + "\n"))
+			So(mock.severity, ShouldEqual, expected)
+			So(mock.capOpts[0].(Data)["raw"], ShouldEqual, line)
+		}
+	})
+
+	Convey("Multi-line writes are split into separate events", t, func() {
+		events := make([]eventFuncMock, 0)
+		mock.onEvent = func(e eventFuncMock) {
+			events = append(events, e)
+		}
+		defer func() {
+			mock.onEvent = nil
+		}()
+
+		captureLogger{}.Write([]byte("first line\n\nsecond line\n\tindented continuation\nthird line\n"))
+
+		So(events, ShouldHaveLength, 3)
+		So(events[0].capOpts[0].(Data)["raw"], ShouldEqual, "first line")
+		So(events[1].capOpts[0].(Data)["raw"], ShouldEqual, "second line\n\tindented continuation")
+		So(events[2].capOpts[0].(Data)["raw"], ShouldEqual, "third line")
+	})
+
+	Convey("NewStdLogger uses the configured event name and namespace", t, func() {
+		l := NewStdLogger("http server logs", "net/http")
+		l.Print("[WARN] test")
+
+		So(mock.capEvent, ShouldEqual, "http server logs")
+		So(mock.severity, ShouldEqual, WARN)
+		So(mock.capOpts, ShouldHaveLength, 2)
+		So(mock.capOpts[0].(Data)["caller"], ShouldStartWith, "go_logger_test.go:")
+
+		evt := createEvent(nil, mock.capEvent, mock.severity, mock.capOpts...)
+		So(evt.Namespace, ShouldEqual, "net/http")
+	})
+
+	Convey("NewStdLogger uses the default event name and namespace if they are empty", t, func() {
+		l := NewStdLogger("", "")
+		l.Print("test")
+
+		So(mock.capEvent, ShouldEqual, "third party logs")
+		So(mock.capOpts, ShouldHaveLength, 1)
+	})
+}
+
+func TestCaptureStdLog(t *testing.T) {
+	Convey("RestoreStdLog restores the original output of the default go logger", t, func() {
+		So(log.Writer(), ShouldHaveSameTypeAs, &captureLogger{})
+
+		RestoreStdLog()
+		So(log.Writer(), ShouldEqual, os.Stderr)
+		So(log.Flags(), ShouldEqual, log.LstdFlags)
+
+		Convey("And CaptureStdLog captures it again", func() {
+			CaptureStdLog()
+			So(log.Writer(), ShouldHaveSameTypeAs, &captureLogger{})
+			So(log.Flags()&log.Lshortfile, ShouldNotEqual, 0)
+		})
+	})
+
+	Convey("CaptureStdLog and RestoreStdLog can be called repeatedly", t, func() {
+		CaptureStdLog()
+		CaptureStdLog()
+		RestoreStdLog()
+		RestoreStdLog()
+		So(log.Writer(), ShouldEqual, os.Stderr)
+		CaptureStdLog()
+		So(log.Writer(), ShouldHaveSameTypeAs, &captureLogger{})
+	})
+}
+
+func TestWriter(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("Given a Writer", t, func() {
+		events := make([]eventFuncMock, 0)
+		mock.onEvent = func(e eventFuncMock) {
+			events = append(events, e)
+		}
+		w := Writer(WARN, "command output")
+
+		Convey("Each complete line is logged with the given severity and event", func() {
+			w.Write([]byte("first line\nsecond "))
+			So(events, ShouldHaveLength, 1)
+			So(events[0].capEvent, ShouldEqual, "command output")
+			So(events[0].severity, ShouldEqual, WARN)
+			So(events[0].capOpts[0].(Data)["raw"], ShouldEqual, "first line")
+
+			w.Write([]byte("line\n[ERROR] third line\n"))
+			So(events, ShouldHaveLength, 3)
+			So(events[1].capOpts[0].(Data)["raw"], ShouldEqual, "second line")
+			So(events[2].severity, ShouldEqual, WARN)
+		})
+
+		Convey("Close logs an incomplete line", func() {
+			w.Write([]byte("no newline"))
+			So(events, ShouldHaveLength, 0)
+			So(w.Close(), ShouldBeNil)
+			So(events, ShouldHaveLength, 1)
+			So(events[0].capOpts[0].(Data)["raw"], ShouldEqual, "no newline")
+		})
+
+		Convey("The output of an exec.Cmd can be captured", func() {
+			cmd := exec.Command("sh", "-c", "echo out-line-1; echo out-line-2")
+			cmd.Stdout = w
+			So(cmd.Run(), ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+
+			So(events, ShouldHaveLength, 2)
+			So(events[0].capOpts[0].(Data)["raw"], ShouldEqual, "out-line-1")
+			So(events[1].capOpts[0].(Data)["raw"], ShouldEqual, "out-line-2")
+		})
+	})
+}
+
+func TestSplitCapturedLines(t *testing.T) {
+	Convey("A goroutine stack trace is kept with the line before it", t, func() {
+		lines := splitCapturedLines("panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d\n")
+		So(lines, ShouldResemble, []string{"panic: boom\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d"})
+	})
+}
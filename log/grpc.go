@@ -0,0 +1,69 @@
+package log
+
+import (
+	"strings"
+	"time"
+)
+
+// EventGRPC is the data structure used for logging a gRPC event.
+//
+// It isn't very useful to export, other than for documenting the
+// data structure it outputs.
+type EventGRPC struct {
+	Service string `json:"service,omitempty"`
+	Method  string `json:"method,omitempty"`
+	Peer    string `json:"peer,omitempty"`
+	Code    string `json:"code,omitempty"`
+
+	// Message counts
+	MessagesReceived int `json:"messages_received,omitempty"`
+	MessagesSent     int `json:"messages_sent,omitempty"`
+
+	// Timing data
+	StartedAt *time.Time     `json:"started_at,omitempty"`
+	EndedAt   *time.Time     `json:"ended_at,omitempty"`
+	Duration  *time.Duration `json:"duration,omitempty"`
+}
+
+func (l *EventGRPC) attach(le *EventData) {
+	le.GRPC = l
+}
+
+// GRPC returns an option you can pass to Event to log gRPC
+// call data with a log event.
+//
+// It splits the full method name (e.g. "/package.Service/Method")
+// into the service and method names.
+//
+// The code is the name of the gRPC status code, e.g. "OK", and is
+// normally empty for an event logged before the call has completed.
+//
+// It also calculates the duration if both startedAt and endedAt are
+// passed in. See the loggrpc package for interceptors which log gRPC
+// calls using this option.
+func GRPC(fullMethod, peer, code string, messagesReceived, messagesSent int, startedAt, endedAt *time.Time) option {
+	service, method := "", strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		service, method = method[:i], method[i+1:]
+	}
+
+	var duration *time.Duration
+	if startedAt != nil && endedAt != nil {
+		d := endedAt.Sub(*startedAt)
+		duration = &d
+	}
+
+	return &EventGRPC{
+		Service: service,
+		Method:  method,
+		Peer:    peer,
+		Code:    code,
+
+		MessagesReceived: messagesReceived,
+		MessagesSent:     messagesSent,
+
+		StartedAt: startedAt,
+		EndedAt:   endedAt,
+		Duration:  duration,
+	}
+}
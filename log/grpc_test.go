@@ -0,0 +1,50 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGRPC(t *testing.T) {
+	Convey("GRPC function returns a *EventGRPC", t, func() {
+		eventGRPC := GRPC("/package.Service/Method", "", "", 0, 0, nil, nil)
+		So(eventGRPC, ShouldHaveSameTypeAs, &EventGRPC{})
+		So(eventGRPC, ShouldImplement, (*option)(nil))
+
+		Convey("*EventGRPC has the correct fields", func() {
+			startTime := time.Now().UTC().Add(time.Second * -1)
+			endTime := time.Now().UTC()
+
+			grpcEvent := GRPC("/package.Service/Method", "127.0.0.1:1234", "NotFound", 2, 3, &startTime, &endTime).(*EventGRPC)
+
+			So(grpcEvent.Service, ShouldEqual, "package.Service")
+			So(grpcEvent.Method, ShouldEqual, "Method")
+			So(grpcEvent.Peer, ShouldEqual, "127.0.0.1:1234")
+			So(grpcEvent.Code, ShouldEqual, "NotFound")
+			So(grpcEvent.MessagesReceived, ShouldEqual, 2)
+			So(grpcEvent.MessagesSent, ShouldEqual, 3)
+			So(grpcEvent.StartedAt, ShouldEqual, &startTime)
+			So(grpcEvent.EndedAt, ShouldEqual, &endTime)
+			So(grpcEvent.Duration, ShouldNotBeNil)
+			So(*grpcEvent.Duration, ShouldEqual, endTime.Sub(startTime))
+		})
+
+		Convey("A method name without a service is kept as the method", func() {
+			grpcEvent := GRPC("Method", "", "", 0, 0, nil, nil).(*EventGRPC)
+			So(grpcEvent.Service, ShouldBeEmpty)
+			So(grpcEvent.Method, ShouldEqual, "Method")
+		})
+	})
+
+	Convey("*EventGRPC can be attached to *EventData", t, func() {
+		event := &EventData{}
+		So(event.GRPC, ShouldBeNil)
+
+		eventGRPC := EventGRPC{}
+		eventGRPC.attach(event)
+
+		So(event.GRPC, ShouldResemble, &eventGRPC)
+	})
+}
@@ -26,6 +26,16 @@ type EventHTTP struct {
 	EndedAt               *time.Time     `json:"ended_at,omitempty"`
 	Duration              *time.Duration `json:"duration,omitempty"`
 	ResponseContentLength int64          `json:"response_content_length,omitempty"`
+
+	// Streaming data, see Middleware
+	TimeToHeaders      *time.Duration `json:"time_to_headers,omitempty"`
+	TimeToFirstByte    *time.Duration `json:"time_to_first_byte,omitempty"`
+	FlushCount         int            `json:"flush_count,omitempty"`
+	ClientDisconnected bool           `json:"client_disconnected,omitempty"`
+
+	// Captured bodies, see WithBodyCapture
+	RequestBody  *EventHTTPBody `json:"request_body,omitempty"`
+	ResponseBody *EventHTTPBody `json:"response_body,omitempty"`
 }
 
 func (l *EventHTTP) attach(le *EventData) {
@@ -44,6 +54,12 @@ func (l *EventHTTP) attach(le *EventData) {
 // It also calculates the duration if both startedAt and endedAt are
 // passed in, for example when wrapping a http.Handler.
 func HTTP(req *http.Request, statusCode int, responseContentLength int64, startedAt, endedAt *time.Time) option {
+	return newEventHTTP(req, statusCode, responseContentLength, startedAt, endedAt)
+}
+
+// newEventHTTP creates the *EventHTTP returned by HTTP, so that callers within
+// the package can set additional fields before logging it
+func newEventHTTP(req *http.Request, statusCode int, responseContentLength int64, startedAt, endedAt *time.Time) *EventHTTP {
 	port := 0
 	if p := req.URL.Port(); p != "" {
 		port, _ = strconv.Atoi(p)
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"log"
+	"regexp"
+)
+
+// httpServerErrorEvent is the event name for logs from http.Server.ErrorLog
+const httpServerErrorEvent = "http server error"
+
+// HTTPServerErrorLog returns a standard library *log.Logger for use as the
+// ErrorLog of a http.Server, for example:
+//
+//	srv := &http.Server{
+//		Addr:     ":8080",
+//		Handler:  log.Middleware(router),
+//		ErrorLog: log.HTTPServerErrorLog(),
+//	}
+//
+// Known net/http messages (e.g. TLS handshake errors, panics and superfluous
+// WriteHeader calls) are logged as "http server error" events with the remote
+// address, caller and error as separate fields and an appropriate severity.
+// Other messages are logged in the same way as NewStdLogger.
+func HTTPServerErrorLog() *log.Logger {
+	return log.New(httpServerErrorLogger{}, "", 0)
+}
+
+// httpServerMessages are the known messages logged by net/http, with the
+// severity they are logged at and the names of the fields for each
+// submatch of the pattern
+var httpServerMessages = []struct {
+	pattern  *regexp.Regexp
+	severity severity
+	fields   []string
+}{
+	{regexp.MustCompile(`^http: TLS handshake error from (\S+): (.*)$`), WARN, []string{"remote_addr", "error"}},
+	{regexp.MustCompile(`(?s)^http: panic serving (\S+): (.*?)(?:\n(goroutine .*))?$`), ERROR, []string{"remote_addr", "error", "stack"}},
+	{regexp.MustCompile(`^http: Accept error: (.*); retrying in (\S+)$`), ERROR, []string{"error", "retry_in"}},
+	{regexp.MustCompile(`^http: superfluous response\.WriteHeader call from (\S+) \((\S+)\)$`), WARN, []string{"function", "caller"}},
+	{regexp.MustCompile(`^http: response\.(WriteHeader|Write) on hijacked connection from (\S+) \((\S+)\)$`), WARN, []string{"call", "function", "caller"}},
+	{regexp.MustCompile(`^http: URL query contains semicolon`), WARN, nil},
+	{regexp.MustCompile(`^http2: server: error reading preface from client (\S+): (.*)$`), WARN, []string{"remote_addr", "error"}},
+	{regexp.MustCompile(`^http2: (.*)$`), WARN, []string{"error"}},
+}
+
+// httpServerErrorLogger is an io.Writer which converts the output of
+// http.Server.ErrorLog to structured events
+type httpServerErrorLogger struct{}
+
+func (h httpServerErrorLogger) Write(b []byte) (n int, err error) {
+	for _, line := range splitCapturedLines(string(b)) {
+		h.logLine(line)
+	}
+	return len(b), nil
+}
+
+func (h httpServerErrorLogger) logLine(line string) {
+	for _, msg := range httpServerMessages {
+		m := msg.pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		data := Data{"raw": line}
+		for i, field := range msg.fields {
+			if v := m[i+1]; v != "" {
+				data[field] = v
+			}
+		}
+
+		//nolint:staticcheck // Passing nil context here is intentional
+		Event(nil, httpServerErrorEvent, msg.severity, data)
+		return
+	}
+
+	captureLogger{event: httpServerErrorEvent}.logLine(line)
+}
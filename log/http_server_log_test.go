@@ -0,0 +1,94 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHTTPServerErrorLog(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("Known net/http messages are logged with structured fields", t, func() {
+		l := HTTPServerErrorLog()
+
+		Convey("TLS handshake errors", func() {
+			l.Printf("http: TLS handshake error from 10.0.0.1:51234: remote error: tls: bad certificate")
+			So(mock.capEvent, ShouldEqual, "http server error")
+			So(mock.severity, ShouldEqual, WARN)
+			data := mock.capOpts[0].(Data)
+			So(data["remote_addr"], ShouldEqual, "10.0.0.1:51234")
+			So(data["error"], ShouldEqual, "remote error: tls: bad certificate")
+		})
+
+		Convey("Accept errors", func() {
+			l.Printf("http: Accept error: too many open files; retrying in 5ms")
+			So(mock.severity, ShouldEqual, ERROR)
+			data := mock.capOpts[0].(Data)
+			So(data["error"], ShouldEqual, "too many open files")
+			So(data["retry_in"], ShouldEqual, "5ms")
+		})
+
+		Convey("Superfluous WriteHeader calls", func() {
+			l.Printf("http: superfluous response.WriteHeader call from main.handler (main.go:42)")
+			So(mock.severity, ShouldEqual, WARN)
+			data := mock.capOpts[0].(Data)
+			So(data["function"], ShouldEqual, "main.handler")
+			So(data["caller"], ShouldEqual, "main.go:42")
+		})
+
+		Convey("Writes on hijacked connections", func() {
+			l.Printf("http: response.Write on hijacked connection from main.handler (main.go:50)")
+			So(mock.severity, ShouldEqual, WARN)
+			data := mock.capOpts[0].(Data)
+			So(data["call"], ShouldEqual, "Write")
+			So(data["caller"], ShouldEqual, "main.go:50")
+		})
+
+		Convey("Unknown messages use severity detection", func() {
+			l.Printf("[ERROR] something else")
+			So(mock.capEvent, ShouldEqual, "http server error")
+			So(mock.severity, ShouldEqual, ERROR)
+			So(mock.capOpts[0].(Data)["raw"], ShouldEqual, "[ERROR] something else")
+		})
+	})
+
+	Convey("Panics in a http.Server handler are logged with the stack trace", t, func() {
+		events := make([]eventFuncMock, 0)
+		mock.onEvent = func(e eventFuncMock) {
+			if e.capEvent == "http server error" {
+				events = append(events, e)
+			}
+		}
+		defer func() {
+			mock.onEvent = nil
+		}()
+
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			panic("handler panic")
+		}))
+		srv.Config.ErrorLog = HTTPServerErrorLog()
+		srv.Start()
+
+		resp, err := http.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		srv.Close()
+
+		So(events, ShouldHaveLength, 1)
+		So(events[0].severity, ShouldEqual, ERROR)
+		data := events[0].capOpts[0].(Data)
+		So(data["remote_addr"], ShouldStartWith, "127.0.0.1:")
+		So(data["error"], ShouldEqual, "handler panic")
+		So(strings.HasPrefix(data["stack"].(string), "goroutine "), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,156 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultJournaldAddress is the socket of the systemd journal native protocol
+const DefaultJournaldAddress = "/run/systemd/journal/socket"
+
+// JournaldEncoder encodes events using the systemd journal native protocol.
+//
+// The event is the MESSAGE field, the severity is mapped to PRIORITY, and the
+// namespace is the SYSLOG_IDENTIFIER. Data is included as upper case journal
+// fields, e.g. Data{"file_name": "a.csv"} is FILE_NAME=a.csv, and nested
+// values are encoded as JSON.
+var JournaldEncoder Encoder = encodeJournald
+
+// AddJournaldSink registers a sink which writes events to the systemd journal.
+// The address defaults to DefaultJournaldAddress if it's empty.
+//
+// Events larger than the maximum datagram size can't be written to the journal,
+// and are counted as failed writes.
+func AddJournaldSink(address string, sinkOpts ...SinkOption) (*Sink, error) {
+	if address == "" {
+		address = DefaultJournaldAddress
+	}
+
+	w, err := dialSocket("unixgram", address, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return AddSink(w, append([]SinkOption{SinkEncoder(JournaldEncoder), sinkClosesWriter()}, sinkOpts...)...), nil
+}
+
+// encodeJournald implements JournaldEncoder. The last field isn't followed
+// by a newline, since a sink adds one after each event.
+func encodeJournald(e EventData) ([]byte, error) {
+	var fields []string
+	var buf bytes.Buffer
+
+	add := func(name, value string) {
+		fields = append(fields, name)
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		if !strings.Contains(value, "\n") {
+			buf.WriteString(name + "=" + value)
+			return
+		}
+		// values containing newlines are written as the field name followed by
+		// the little endian 64 bit length of the value and the value
+		buf.WriteString(name + "\n")
+		_ = binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value)
+	}
+
+	add("MESSAGE", e.Event)
+	add("PRIORITY", strconv.Itoa(syslogSeverity(e.Severity)))
+	add("SYSLOG_IDENTIFIER", e.Namespace)
+	add("NAMESPACE", e.Namespace)
+	if e.TraceID != "" {
+		add("TRACE_ID", e.TraceID)
+	}
+	if e.SpanID != "" {
+		add("SPAN_ID", e.SpanID)
+	}
+	if e.HTTP != nil {
+		add("HTTP_METHOD", e.HTTP.Method)
+		add("HTTP_PATH", e.HTTP.Path)
+		if e.HTTP.StatusCode != nil && *e.HTTP.StatusCode != 0 {
+			add("HTTP_STATUS_CODE", strconv.Itoa(*e.HTTP.StatusCode))
+		}
+		if e.HTTP.Duration != nil {
+			add("HTTP_DURATION", e.HTTP.Duration.String())
+		}
+	}
+	if e.Auth != nil && e.Auth.Identity != "" {
+		add("AUTH_IDENTITY", e.Auth.Identity)
+	}
+	if e.Errors != nil {
+		for i, err := range *e.Errors {
+			// journal fields can have more than one value
+			add("ERROR", err.Message)
+			if i == 0 && len(err.StackTrace) > 0 {
+				add("CODE_FILE", err.StackTrace[0].File)
+				add("CODE_LINE", strconv.Itoa(err.StackTrace[0].Line))
+				add("CODE_FUNC", err.StackTrace[0].Function)
+			}
+		}
+	}
+
+	if e.Data != nil {
+		reserved := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			reserved[f] = true
+		}
+
+		keys := make([]string, 0, len(*e.Data))
+		for k := range *e.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			name := journaldFieldName(k)
+			if reserved[name] || name == "" {
+				name = "DATA_" + name
+			}
+
+			var value string
+			switch v := (*e.Data)[k].(type) {
+			case string:
+				value = v
+			case fmt.Stringer:
+				value = v.String()
+			default:
+				b, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				value = string(b)
+			}
+			add(name, value)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// journaldFieldName converts k to a valid journal field name, which can only
+// contain upper case letters, digits and underscores, can't start with an
+// underscore or digit, and is at most 64 characters
+func journaldFieldName(k string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, k)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 59 {
+		// leave room for a DATA_ prefix
+		name = name[:59]
+	}
+	return name
+}
@@ -0,0 +1,97 @@
+//go:build !windows
+
+package log
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJournaldEncoder(t *testing.T) {
+	Convey("JournaldEncoder encodes an event as journal fields", t, func() {
+		sev := WARN
+		status := 500
+		e := EventData{
+			CreatedAt: time.Now(),
+			Namespace: "app",
+			Event:     "test",
+			TraceID:   "abc123",
+			Severity:  &sev,
+			HTTP:      &EventHTTP{Method: "GET", Path: "/foo", StatusCode: &status},
+			Data:      &Data{"file-name": "a.csv", "message": "clash", "_count": 2, "nested": Data{"a": 1}},
+		}
+
+		b, err := JournaldEncoder(e)
+		So(err, ShouldBeNil)
+		So(strings.Split(string(b), "\n"), ShouldResemble, []string{
+			"MESSAGE=test",
+			"PRIORITY=4",
+			"SYSLOG_IDENTIFIER=app",
+			"NAMESPACE=app",
+			"TRACE_ID=abc123",
+			"HTTP_METHOD=GET",
+			"HTTP_PATH=/foo",
+			"HTTP_STATUS_CODE=500",
+			"COUNT=2",
+			"FILE_NAME=a.csv",
+			"DATA_MESSAGE=clash",
+			`NESTED={"a":1}`,
+		})
+	})
+
+	Convey("Values containing newlines use the binary format", t, func() {
+		b, err := JournaldEncoder(EventData{Event: "line 1\nline 2"})
+		So(err, ShouldBeNil)
+
+		s := string(b)
+		So(s, ShouldStartWith, "MESSAGE\n")
+		So(binary.LittleEndian.Uint64(b[8:16]), ShouldEqual, 13)
+		So(s[16:30], ShouldEqual, "line 1\nline 2\n")
+	})
+
+	Convey("Field names are made valid", t, func() {
+		So(journaldFieldName("requestId"), ShouldEqual, "REQUESTID")
+		So(journaldFieldName("9lives"), ShouldEqual, "LIVES")
+		So(journaldFieldName("__"), ShouldEqual, "")
+		So(journaldFieldName(strings.Repeat("a", 100)), ShouldHaveLength, 59)
+	})
+}
+
+func TestJournaldSink(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+	destination = &syncBuffer{}
+
+	Convey("Given a journald sink", t, func() {
+		conn := listenUnixgram(t)
+
+		sink, err := AddJournaldSink(conn.LocalAddr().String())
+		So(err, ShouldBeNil)
+		defer sink.Close()
+
+		Convey("Events are written to the socket with newline terminated fields", func() {
+			Error(context.Background(), "journald test", errors.New("test error"))
+
+			msg, err := readDatagram(conn)
+			So(err, ShouldBeNil)
+			So(msg, ShouldStartWith, "MESSAGE=journald test\nPRIORITY=3\n")
+			So(msg, ShouldContainSubstring, "\nERROR=test error\n")
+			So(msg, ShouldContainSubstring, "\nCODE_FILE=")
+			So(msg, ShouldEndWith, "\n")
+		})
+	})
+}
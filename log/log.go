@@ -83,6 +83,23 @@ func Info(ctx context.Context, event string, opts ...option) {
 	eventFuncInst.f(ctx, event, INFO, opts...)
 }
 
+// Debug wraps the Event function with the severity level set to DEBUG
+//
+// DEBUG events are not logged unless the minimum severity is set to DEBUG
+// (see SetMinimumSeverity), or they are logged with a context which has
+// a debug buffer attached (see ContextWithDebugBuffer).
+func Debug(ctx context.Context, event string, opts ...option) {
+	eventFuncInst.f(ctx, event, DEBUG, opts...)
+}
+
+// Trace wraps the Event function with the severity level set to TRACE
+//
+// TRACE events are the most verbose, and are handled in the same way
+// as DEBUG events.
+func Trace(ctx context.Context, event string, opts ...option) {
+	eventFuncInst.f(ctx, event, TRACE, opts...)
+}
+
 // Warn wraps the Event function with the severity level set to WARN
 func Warn(ctx context.Context, event string, opts ...option) {
 	eventFuncInst.f(ctx, event, WARN, opts...)
@@ -136,6 +153,10 @@ func initStyler() *styleFunc {
 		return styleForHumanFunc
 	}
 
+	if enc, ok := encoders[os.Getenv("LOG_FORMAT")]; ok {
+		return encoderStyler(enc)
+	}
+
 	return styleForMachineFunc
 }
 
@@ -173,15 +194,53 @@ type EventData struct {
 	SpanID   string    `json:"span_id,omitempty"`
 	Severity *severity `json:"severity,omitempty"`
 
+	// Buffered is true for events which were held in a debug buffer
+	// and logged later, see ContextWithDebugBuffer
+	Buffered bool `json:"buffered,omitempty"`
+
 	// Optional nested data
 	HTTP *EventHTTP `json:"http,omitempty"`
-	Auth *eventAuth `json:"auth,omitempty"`
+	GRPC *EventGRPC `json:"grpc,omitempty"`
+	Auth *EventAuth `json:"auth,omitempty"`
 	Data *Data      `json:"data,omitempty"`
 
 	// Error data
 	Errors *EventErrors `json:"errors,omitempty"`
 }
 
+// snapshot returns a copy of e which doesn't share any data with it, so that
+// it can be kept and logged later even if the caller changes what it logged
+func (e *EventData) snapshot() *EventData {
+	c := *e
+
+	if e.HTTP != nil {
+		h := *e.HTTP
+		c.HTTP = &h
+	}
+	if e.GRPC != nil {
+		g := *e.GRPC
+		c.GRPC = &g
+	}
+	if e.Auth != nil {
+		c.Auth = e.Auth.snapshot()
+	}
+	if e.Data != nil {
+		d := copyData(*e.Data)
+		c.Data = &d
+	}
+	if e.Errors != nil {
+		errs := make(EventErrors, len(*e.Errors))
+		for i, err := range *e.Errors {
+			err.StackTrace = append([]EventStackTrace(nil), err.StackTrace...)
+			err.Data = copyDataValue(err.Data)
+			errs[i] = err
+		}
+		c.Errors = &errs
+	}
+
+	return &c
+}
+
 // eventWithOptionsCheck is the event function used when running tests, and
 // will panic if the same log option is passed in multiple times
 //
@@ -205,8 +264,50 @@ func eventWithOptionsCheck(ctx context.Context, event string, severity severity,
 // eventWithoutOptionsCheck is the event function used when we're not running tests
 //
 // It doesn't do any log options checks to minimise the runtime performance overhead
+//
+// Events less severe than the minimum severity are discarded, or held in the
+// debug buffer attached to the context if there is one. ERROR and FATAL events
+// flush the debug buffer before they are logged, even if they are suppressed
+// by sampling.
 func eventWithoutOptionsCheck(ctx context.Context, event string, severity severity, opts ...option) {
-	printEvent(styler.f(ctx, *createEvent(ctx, event, severity, opts...), eventFunc{eventWithoutOptionsCheck}))
+	buf := debugBufferFromContext(ctx)
+	recording := flightRecorder.Load() != nil
+	enabled := severityEnabled(ctx, severity)
+
+	if !enabled && buf == nil && !recording {
+		return
+	}
+
+	e := createEvent(ctx, event, severity, opts...)
+	if recording {
+		recordEvent(e)
+	}
+
+	if !enabled {
+		if buf != nil {
+			e.Buffered = true
+			buf.add(e)
+		}
+		return
+	}
+
+	if buf != nil && severity <= ERROR {
+		buf.flush(ctx)
+	}
+
+	if sampleEvent(event, severity) {
+		writeEvent(ctx, e)
+	}
+
+	if severity == FATAL && recording {
+		dumpFlightRecorder()
+	}
+}
+
+// writeEvent writes an event to the destination and any sinks
+func writeEvent(ctx context.Context, e *EventData) {
+	dispatchToSinks(e)
+	printEvent(styler.f(ctx, *e, eventFunc{eventWithoutOptionsCheck}))
 }
 
 // createEvent creates a new event struct and attaches the options to it
@@ -220,6 +321,7 @@ func createEvent(ctx context.Context, event string, severity severity, opts ...o
 
 	if ctx != nil {
 		e.TraceID = getRequestID(ctx)
+		e.Auth = authFromContext(ctx)
 	}
 
 	otelTraceID := trace.SpanFromContext(ctx).SpanContext().TraceID()
@@ -298,29 +400,29 @@ func printEvent(b []byte) {
 		return
 	}
 
-	// try and write to stdout
+	line := &pendingLine{b: make([]byte, len(b)+1)}
+	copy(line.b, b)
+	line.b[len(b)] = '\n'
+
 	destinationMutex.Lock()
 	defer destinationMutex.Unlock()
-	if n, err := fmt.Fprintln(destination, string(b)); n != len(b)+1 || err != nil {
-		// if that fails, try and write to stderr
-		fallbackDestinationMutex.Lock()
-		defer fallbackDestinationMutex.Unlock()
-		if n, err := fmt.Fprintln(fallbackDestination, string(b)); n != len(b)+1 || err != nil {
-			// if that fails, panic!
-			//
-			// also defer an os.Exit since the panic might be captured in a recover
-			// block in the caller, but we always want to exit in this scenario
-			//
-			// Note: deferring an os.Exit makes this particular block untestable
-			// using conventional `go test`. But it's a narrow enough edge case that
-			// it probably isn't worth trying, and only occurs in extreme circumstances
-			// (os.Stdout and os.Stderr both being closed) where unpredictable
-			// behaviour is expected. It's not clear what a panic or os.Exit would do
-			// in this scenario, or if our process is even still alive to get this far.
-			defer os.Exit(1)
-			panic("error writing log data: " + err.Error())
+
+	// keep events in order if earlier events are waiting to be written
+	if len(writeBuffer) > 0 {
+		if writeFailureOptions.Policy == RetryOnWriteFailure {
+			retryLater(line)
+			return
+		}
+		if err := flushWriteBuffer(); err != nil {
+			handleWriteFailure(line, err)
+			return
 		}
 	}
+
+	// try and write to stdout, and if that fails, try and write to stderr
+	if err := writeLine(line); err != nil {
+		handleWriteFailure(line, err)
+	}
 }
 
 // SetDestination allows you to set the destination and fallback destination
@@ -0,0 +1,117 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+)
+
+// NewLogr returns a logr.Logger which logs events using this package,
+// for libraries which log using go-logr/logr (e.g. the OpenTelemetry
+// SDK and Kubernetes client-go). See NewLogSink for details.
+func NewLogr() logr.Logger {
+	return logr.New(NewLogSink())
+}
+
+// NewLogSink returns a logr.LogSink which logs events using this package.
+//
+// The message is used as the event name, and key/value pairs are included
+// as Data. Logger names are joined with "/" and included as the "logger"
+// field of Data. V-levels are mapped to severities, so V(0) is INFO, V(1)
+// is DEBUG and V(2) and above are TRACE. Errors are logged as ERROR events
+// including the error.
+func NewLogSink() logr.LogSink {
+	return &logSink{}
+}
+
+// SetOTelLogger sets the logger used by the OpenTelemetry SDK for its
+// internal logs to one backed by this package
+func SetOTelLogger() {
+	otel.SetLogger(NewLogr().WithName("otel"))
+}
+
+type logSink struct {
+	name   string
+	values []interface{}
+}
+
+// vSeverity returns the severity for a logr V-level
+func vSeverity(level int) severity {
+	switch {
+	case level <= 0:
+		return INFO
+	case level == 1:
+		return DEBUG
+	default:
+		return TRACE
+	}
+}
+
+func (l *logSink) Init(logr.RuntimeInfo) {}
+
+func (l *logSink) Enabled(level int) bool {
+	return severityEnabled(nil, vSeverity(level))
+}
+
+func (l *logSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	//nolint:staticcheck // Passing nil context here is intentional
+	Event(nil, msg, vSeverity(level), l.data(keysAndValues))
+}
+
+func (l *logSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err == nil {
+		//nolint:staticcheck // Passing nil context here is intentional
+		Event(nil, msg, ERROR, l.data(keysAndValues))
+		return
+	}
+
+	//nolint:staticcheck // Passing nil context here is intentional
+	Event(nil, msg, ERROR, l.data(keysAndValues), FormatErrors([]error{err}))
+}
+
+func (l *logSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	values := make([]interface{}, 0, len(l.values)+len(keysAndValues))
+	values = append(values, l.values...)
+	values = append(values, keysAndValues...)
+	return &logSink{name: l.name, values: values}
+}
+
+func (l *logSink) WithName(name string) logr.LogSink {
+	if l.name != "" {
+		name = l.name + "/" + name
+	}
+	return &logSink{name: name, values: l.values}
+}
+
+// data converts the sink's values and the key/value pairs of a single call
+// to Data, with values from the call taking precedence
+func (l *logSink) data(keysAndValues []interface{}) Data {
+	d := Data{}
+	if l.name != "" {
+		d["logger"] = l.name
+	}
+
+	for _, kv := range [][]interface{}{l.values, keysAndValues} {
+		for i := 0; i < len(kv); i += 2 {
+			key, ok := kv[i].(string)
+			if !ok {
+				key = fmt.Sprint(kv[i])
+			}
+
+			var value interface{} = "(MISSING)"
+			if i+1 < len(kv) {
+				value = kv[i+1]
+			}
+			// errors don't usually serialise to JSON usefully
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+
+			d[strings.TrimSpace(key)] = value
+		}
+	}
+
+	return d
+}
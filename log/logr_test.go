@@ -0,0 +1,77 @@
+package log
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLogSink(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("Given a logr.Logger backed by a LogSink", t, func() {
+		l := NewLogr()
+
+		Convey("Info events are logged with key/value pairs as Data", func() {
+			l.Info("reconciling", "namespace", "default", "count", 3)
+			So(mock.capEvent, ShouldEqual, "reconciling")
+			So(mock.severity, ShouldEqual, INFO)
+			So(mock.capOpts, ShouldHaveLength, 1)
+			So(mock.capOpts[0], ShouldResemble, Data{"namespace": "default", "count": 3})
+		})
+
+		Convey("V-levels are mapped to severities", func() {
+			defer SetMinimumSeverity(INFO)
+			SetMinimumSeverity(TRACE)
+
+			l.V(1).Info("debug")
+			So(mock.severity, ShouldEqual, DEBUG)
+			l.V(2).Info("trace")
+			So(mock.severity, ShouldEqual, TRACE)
+			l.V(5).Info("trace")
+			So(mock.severity, ShouldEqual, TRACE)
+		})
+
+		Convey("V-levels below the minimum severity are not enabled", func() {
+			So(l.V(0).Enabled(), ShouldBeTrue)
+			So(l.V(1).Enabled(), ShouldBeFalse)
+		})
+
+		Convey("Names and values are included in Data", func() {
+			l.WithName("otel").WithName("exporter").WithValues("a", 1).Info("event", "b", errors.New("oops"), 3)
+			So(mock.capOpts[0], ShouldResemble, Data{"logger": "otel/exporter", "a": 1, "b": "oops", "3": "(MISSING)"})
+		})
+
+		Convey("WithValues doesn't change the parent logger", func() {
+			_ = l.WithValues("a", 1)
+			l.Info("event")
+			So(mock.capOpts[0], ShouldResemble, Data{})
+		})
+
+		Convey("Errors are logged as ERROR events including the error", func() {
+			l.Error(errors.New("export failed"), "failed to export spans", "count", 2)
+			So(mock.capEvent, ShouldEqual, "failed to export spans")
+			So(mock.severity, ShouldEqual, ERROR)
+			So(mock.capOpts, ShouldHaveLength, 2)
+			So(mock.capOpts[0], ShouldResemble, Data{"count": 2})
+			errs := mock.capOpts[1].(*EventErrors)
+			So((*errs)[0].Message, ShouldEqual, "export failed")
+		})
+
+		Convey("A nil error is logged without errors", func() {
+			l.Error(nil, "failed")
+			So(mock.severity, ShouldEqual, ERROR)
+			So(mock.capOpts, ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("SetOTelLogger doesn't panic", t, func() {
+		So(SetOTelLogger, ShouldNotPanic)
+	})
+}
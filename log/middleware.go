@@ -2,15 +2,65 @@ package log
 
 import (
 	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/ONSdigital/dp-net/v3/request"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 )
 
+// MiddlewareOption is an option you can pass to Middleware to change
+// how requests are logged
+type MiddlewareOption func(*middlewareConfig)
+
+// middlewareConfig holds the configuration built from the MiddlewareOption
+// values passed to Middleware
+type middlewareConfig struct {
+	bodyCapture        *BodyCapture
+	requestIDSize      int
+	identityExtractor  IdentityExtractor
+	debugBufferSize    int
+	severityAuthoriser SeverityOverrideAuthoriser
+}
+
+// DefaultRequestIDSize is the length of request IDs generated by Middleware
+// when WithRequestID is passed a size of zero or less
+const DefaultRequestIDSize = 16
+
+// httpRequestCompletedEvent is the event logged by Middleware after each
+// request, which includes the response status code and duration
+const httpRequestCompletedEvent = "http request completed"
+
+// MaxRequestIDLength is the maximum length of an inbound X-Request-Id header.
+// Longer IDs, or IDs containing characters other than printable ASCII, are
+// replaced with a new request ID.
+const MaxRequestIDLength = 128
+
+// WithRequestID returns a MiddlewareOption which makes sure every request
+// has a request ID.
+//
+// The ID is taken from the request context if an earlier handler has already
+// set one, otherwise from the inbound X-Request-Id header. If neither exist,
+// a new random ID of the given size is generated.
+//
+// The ID is stored in the request context (so it is logged as the trace_id
+// of events using that context), set on the request X-Request-Id header, and
+// echoed on the response X-Request-Id header.
+func WithRequestID(size int) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		if size <= 0 {
+			size = DefaultRequestIDSize
+		}
+		cfg.requestIDSize = size
+	}
+}
+
 // Middleware implements the logger middleware and captures HTTP request data
 //
 // It implements http.Handler, and wraps an inbound HTTP request to log useful
@@ -23,8 +73,22 @@ import (
 // Each request will produce two log entries - one when the request is received,
 // and another when the response has completed.
 //
+// The completed event also includes the time taken to write the response
+// headers and the first byte of the body, the number of times the response
+// was flushed, and whether the client disconnected before the response
+// completed. These help to tell a slow handler apart from a slow client,
+// for example when streaming a large download.
+//
+// Additional behaviour can be enabled by passing in options, for example
+// WithBodyCapture.
+//
 // See the Event and HTTP functions for additional information.
-func Middleware(f http.Handler) http.Handler {
+func Middleware(f http.Handler, opts ...MiddlewareOption) http.Handler {
+	cfg := &middlewareConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		if req == nil {
 			//nolint:staticcheck // Passing nil context here is intentional
@@ -32,8 +96,31 @@ func Middleware(f http.Handler) http.Handler {
 			return
 		}
 
-		rc := &responseCapture{w, nil, 0}
+		if cfg.requestIDSize > 0 {
+			req = withRequestIDFromRequest(w, req, cfg.requestIDSize)
+		}
+
+		if cfg.identityExtractor != nil {
+			if auth := cfg.identityExtractor(req); auth != nil {
+				req = req.WithContext(WithAuth(req.Context(), auth))
+			}
+		}
+
+		if cfg.severityAuthoriser != nil {
+			req = withSeverityFromHeader(req, cfg.severityAuthoriser)
+		}
+
+		if cfg.debugBufferSize > 0 {
+			req = req.WithContext(ContextWithDebugBuffer(req.Context(), cfg.debugBufferSize))
+		}
+
 		start := time.Now().UTC()
+		rc := &responseCapture{ResponseWriter: w, bodyCapture: cfg.bodyCapture}
+
+		var reqBody *bodyBuffer
+		if cfg.bodyCapture != nil {
+			reqBody = cfg.bodyCapture.wrapRequest(req)
+		}
 
 		octx := otel.GetTextMapPropagator().Extract(
 			req.Context(), propagation.HeaderCarrier(req.Header),
@@ -49,21 +136,98 @@ func Middleware(f http.Handler) http.Handler {
 				statusCode = *rc.statusCode
 			}
 
-			Event(octx, "http request completed", INFO, HTTP(req, statusCode, rc.bytesWritten, &start, &end))
+			eventHTTP := newEventHTTP(req, statusCode, rc.bytesWritten, &start, &end)
+			eventHTTP.TimeToHeaders = durationSince(start, rc.headersWrittenAt)
+			eventHTTP.TimeToFirstByte = durationSince(start, rc.firstByteAt)
+			eventHTTP.FlushCount = rc.flushCount
+			// a deadline, e.g. from http.TimeoutHandler, isn't a disconnect
+			eventHTTP.ClientDisconnected = errors.Is(req.Context().Err(), context.Canceled)
+			if cfg.bodyCapture != nil && cfg.bodyCapture.shouldLog(statusCode) {
+				eventHTTP.RequestBody = reqBody.eventBody()
+				eventHTTP.ResponseBody = rc.body.eventBody()
+			}
+
+			if statusCode >= http.StatusInternalServerError {
+				FlushDebugBuffer(octx)
+			} else {
+				DiscardDebugBuffer(octx)
+			}
+
+			Event(octx, httpRequestCompletedEvent, INFO, eventHTTP)
 		}()
 
 		f.ServeHTTP(rc, req)
 	})
 }
 
+// withRequestIDFromRequest returns req with a request ID stored in its context
+// and header, and sets the same ID on the response header
+func withRequestIDFromRequest(w http.ResponseWriter, req *http.Request, size int) *http.Request {
+	requestID := request.GetRequestId(req.Context())
+	if requestID == "" {
+		requestID = req.Header.Get(request.RequestHeaderKey)
+		if !validRequestID(requestID) {
+			// don't echo or log an untrusted value which could be used to
+			// inject content or bloat every event
+			requestID = ""
+			req.Header.Del(request.RequestHeaderKey)
+		}
+	}
+	if requestID == "" {
+		requestID = request.NewRequestID(size)
+	}
+
+	if req.Header.Get(request.RequestHeaderKey) == "" {
+		request.AddRequestIdHeader(req, requestID)
+	}
+	w.Header().Set(request.RequestHeaderKey, requestID)
+
+	return req.WithContext(request.WithRequestId(req.Context(), requestID))
+}
+
+// validRequestID returns true if an inbound request ID is at most
+// MaxRequestIDLength characters of printable ASCII, other than spaces
+func validRequestID(id string) bool {
+	if len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// durationSince returns the duration between start and t, or nil if t is nil
+func durationSince(start time.Time, t *time.Time) *time.Duration {
+	if t == nil {
+		return nil
+	}
+	d := t.Sub(start)
+	return &d
+}
+
 type responseCapture struct {
 	http.ResponseWriter
 	statusCode   *int
 	bytesWritten int64
+
+	// streaming data, see Middleware
+	headersWrittenAt *time.Time
+	firstByteAt      *time.Time
+	flushCount       int
+
+	// bodyCapture is nil unless body capture is enabled, and body is
+	// only created once the response content type has been checked
+	bodyCapture *BodyCapture
+	bodyChecked bool
+	body        *bodyBuffer
 }
 
 func (r *responseCapture) WriteHeader(status int) {
 	r.statusCode = &status
+	r.markHeadersWritten()
 	r.ResponseWriter.WriteHeader(status)
 }
 
@@ -72,20 +236,132 @@ func (r *responseCapture) Write(b []byte) (n int, err error) {
 		s := 200
 		r.statusCode = &s
 	}
+	r.markHeadersWritten()
 	n, err = r.ResponseWriter.Write(b)
 	r.bytesWritten += int64(n)
+	if n > 0 {
+		r.markFirstByte()
+	}
+
+	if r.bodyCapture != nil {
+		r.captureBody(b[:n])
+	}
 	return
 }
 
+// captureBody copies written response bytes into the body buffer, deciding on
+// the first write whether the response should be captured at all
+func (r *responseCapture) captureBody(b []byte) {
+	if !r.bodyChecked {
+		r.bodyChecked = true
+		if r.bodyCapture.OnlyOnError && *r.statusCode < http.StatusBadRequest {
+			return
+		}
+		ct := r.Header().Get("Content-Type")
+		if _, ok := r.Header()["Content-Type"]; !ok && len(b) > 0 {
+			// net/http sniffs the content type of responses which don't set one
+			ct = http.DetectContentType(b)
+		}
+		if r.bodyCapture.allowsContentType(ct) {
+			r.body = newBodyBuffer(ct, r.bodyCapture.maxBytes())
+		}
+	}
+
+	if r.body != nil {
+		r.body.Write(b) //nolint:errcheck // bodyBuffer never returns an error
+	}
+}
+
+// markHeadersWritten records when the response headers were first written.
+//
+// Headers are written when WriteHeader is called, or implicitly by the
+// first call to Write or Flush.
+func (r *responseCapture) markHeadersWritten() {
+	if r.headersWrittenAt == nil {
+		t := time.Now().UTC()
+		r.headersWrittenAt = &t
+	}
+}
+
+// markFirstByte records when the first byte of the response body was written
+func (r *responseCapture) markFirstByte() {
+	if r.firstByteAt == nil {
+		t := time.Now().UTC()
+		r.firstByteAt = &t
+	}
+}
+
 func (r *responseCapture) Flush() {
 	if f, ok := r.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
+		r.flushCount++
+		r.markHeadersWritten()
+	}
+}
+
+// FlushError is used by http.ResponseController in preference to Flush, and
+// returns an error if the underlying http.ResponseWriter can't be flushed.
+// Only successful flushes are counted.
+func (r *responseCapture) FlushError() error {
+	if err := http.NewResponseController(r.ResponseWriter).Flush(); err != nil {
+		return err
 	}
+	r.flushCount++
+	r.markHeadersWritten()
+	return nil
 }
 
 func (r *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
 		return h.Hijack()
 	}
-	return nil, nil, errors.New("log: response does not implement http.Hijacker")
+	return nil, nil, fmt.Errorf("log: response does not implement http.Hijacker: %w", http.ErrNotSupported)
+}
+
+// Push implements http.Pusher, and returns http.ErrNotSupported if the
+// underlying http.ResponseWriter doesn't support server push
+func (r *responseCapture) Push(target string, opts *http.PushOptions) error {
+	if p, ok := r.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// ReadFrom implements io.ReaderFrom, so the underlying http.ResponseWriter can
+// use its own ReadFrom (e.g. sendfile) where possible.
+//
+// If the response body is being captured, the data is copied through Write
+// instead so it can be captured.
+func (r *responseCapture) ReadFrom(src io.Reader) (n int64, err error) {
+	rf, ok := r.ResponseWriter.(io.ReaderFrom)
+	if !ok || r.bodyCapture != nil {
+		return io.Copy(writerOnly{r}, src)
+	}
+
+	if r.statusCode == nil {
+		s := 200
+		r.statusCode = &s
+	}
+	r.markHeadersWritten()
+	n, err = rf.ReadFrom(src)
+	r.bytesWritten += n
+	// the underlying ReadFrom can't be observed part way through, so this
+	// records when the copy completed rather than the actual first byte
+	if n > 0 {
+		r.markFirstByte()
+	}
+	return
+}
+
+// Unwrap returns the underlying http.ResponseWriter, which allows
+// http.ResponseController to reach features (e.g. SetWriteDeadline)
+// that responseCapture doesn't implement itself
+func (r *responseCapture) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// writerOnly hides any io.ReaderFrom implementation, so that io.Copy
+// doesn't call back into responseCapture.ReadFrom
+type writerOnly struct {
+	io.Writer
 }
@@ -3,10 +3,16 @@ package log
 import (
 	"bufio"
 	"context"
+	"errors"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ONSdigital/dp-net/v3/request"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -36,13 +42,13 @@ func (r *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 
 func TestResponseCapture(t *testing.T) {
 	Convey("responseCapture implements http.ResponseWriter", t, func() {
-		r := &responseCapture{&responseWriter{}, nil, 0}
+		r := &responseCapture{ResponseWriter: &responseWriter{}}
 		So(r, ShouldImplement, (*http.ResponseWriter)(nil))
 	})
 
 	Convey("responseCapture implements http.Flusher", t, func() {
 		rw := &responseWriter{}
-		r := &responseCapture{rw, nil, 0}
+		r := &responseCapture{ResponseWriter: rw}
 		So(r, ShouldImplement, (*http.Flusher)(nil))
 		So(rw.flushCalled, ShouldBeFalse)
 		http.Flusher(r).Flush()
@@ -51,7 +57,7 @@ func TestResponseCapture(t *testing.T) {
 
 	Convey("responseCapture implements http.Hijacker", t, func() {
 		rw := &responseWriter{}
-		r := &responseCapture{rw, nil, 0}
+		r := &responseCapture{ResponseWriter: rw}
 		So(r, ShouldImplement, (*http.Hijacker)(nil))
 		So(rw.hijackCalled, ShouldBeFalse)
 		_, _, err := http.Hijacker(r).Hijack()
@@ -60,7 +66,7 @@ func TestResponseCapture(t *testing.T) {
 
 		Convey("Hijack returns an error if the inner http.ResponseWriter isn't a http.Hijacker", func() {
 			rw := &responseWriterWithoutHijacker{}
-			r := &responseCapture{rw, nil, 0}
+			r := &responseCapture{ResponseWriter: rw}
 			_, _, err := http.Hijacker(r).Hijack()
 			So(err, ShouldNotBeNil)
 		})
@@ -68,7 +74,7 @@ func TestResponseCapture(t *testing.T) {
 
 	Convey("responseCapture records the status code", t, func() {
 		Convey("responseCapture records the status code when calling WriteHeader", func() {
-			r := &responseCapture{&responseWriter{}, nil, 0}
+			r := &responseCapture{ResponseWriter: &responseWriter{}}
 			So(r.statusCode, ShouldBeNil)
 			r.WriteHeader(501)
 			So(r.statusCode, ShouldNotBeNil)
@@ -76,7 +82,7 @@ func TestResponseCapture(t *testing.T) {
 		})
 
 		Convey("responseCapture records the status code when skipping WriteHeader", func() {
-			r := &responseCapture{&responseWriter{}, nil, 0}
+			r := &responseCapture{ResponseWriter: &responseWriter{}}
 			So(r.statusCode, ShouldBeNil)
 			r.Write([]byte{})
 			So(r.statusCode, ShouldNotBeNil)
@@ -85,7 +91,7 @@ func TestResponseCapture(t *testing.T) {
 	})
 
 	Convey("responseCapture records the number of bytes written", t, func() {
-		r := &responseCapture{&responseWriter{}, nil, 0}
+		r := &responseCapture{ResponseWriter: &responseWriter{}}
 		So(r.bytesWritten, ShouldEqual, 0)
 
 		r.Write([]byte("abc"))
@@ -208,3 +214,321 @@ func TestMiddleware(t *testing.T) {
 		})
 	})
 }
+
+func TestMiddlewareRequestID(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("Given a middleware with request IDs enabled", t, func() {
+		var handlerRequestID string
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			handlerRequestID = request.GetRequestId(req.Context())
+		})
+		m := Middleware(h, WithRequestID(0))
+
+		var eventRequestIDs []string
+		mock.onEvent = func(e eventFuncMock) {
+			eventRequestIDs = append(eventRequestIDs, getRequestID(e.capCtx))
+		}
+
+		Convey("When the request has an X-Request-Id header", func() {
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.Header.Set(request.RequestHeaderKey, "inbound-id")
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			Convey("Then the inbound ID is used and echoed on the response", func() {
+				So(handlerRequestID, ShouldEqual, "inbound-id")
+				So(eventRequestIDs, ShouldResemble, []string{"inbound-id", "inbound-id"})
+				So(rec.Header().Get(request.RequestHeaderKey), ShouldEqual, "inbound-id")
+			})
+		})
+
+		Convey("When the request has an invalid X-Request-Id header", func() {
+			for _, id := range []string{strings.Repeat("a", MaxRequestIDLength+1), "bad\nid", "bad id", "bäd"} {
+				req := httptest.NewRequest("GET", "/", http.NoBody)
+				req.Header.Set(request.RequestHeaderKey, id)
+				rec := httptest.NewRecorder()
+				m.ServeHTTP(rec, req)
+
+				So(handlerRequestID, ShouldHaveLength, DefaultRequestIDSize)
+				So(rec.Header().Get(request.RequestHeaderKey), ShouldEqual, handlerRequestID)
+			}
+		})
+
+		Convey("When the request context already has a request ID", func() {
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req = req.WithContext(request.WithRequestId(req.Context(), "context-id"))
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			Convey("Then the context ID is used", func() {
+				So(handlerRequestID, ShouldEqual, "context-id")
+				So(rec.Header().Get(request.RequestHeaderKey), ShouldEqual, "context-id")
+			})
+		})
+
+		Convey("When the request has no request ID", func() {
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			Convey("Then a new ID is generated", func() {
+				So(handlerRequestID, ShouldHaveLength, DefaultRequestIDSize)
+				So(eventRequestIDs, ShouldResemble, []string{handlerRequestID, handlerRequestID})
+				So(rec.Header().Get(request.RequestHeaderKey), ShouldEqual, handlerRequestID)
+			})
+		})
+	})
+
+	Convey("Given a middleware without request IDs enabled", t, func() {
+		var handlerRequestID string
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			handlerRequestID = request.GetRequestId(req.Context())
+		})
+		rec := httptest.NewRecorder()
+		Middleware(h).ServeHTTP(rec, httptest.NewRequest("GET", "/", http.NoBody))
+
+		Convey("Then no request ID is generated", func() {
+			So(handlerRequestID, ShouldBeEmpty)
+			So(rec.Header().Get(request.RequestHeaderKey), ShouldBeEmpty)
+		})
+	})
+}
+
+// basicResponseWriter implements http.ResponseWriter and nothing else
+type basicResponseWriter struct {
+	header  http.Header
+	written []byte
+}
+
+func (b *basicResponseWriter) Header() http.Header {
+	if b.header == nil {
+		b.header = http.Header{}
+	}
+	return b.header
+}
+func (b *basicResponseWriter) WriteHeader(status int) {}
+func (b *basicResponseWriter) Write(p []byte) (int, error) {
+	b.written = append(b.written, p...)
+	return len(p), nil
+}
+
+type readerFromResponseWriter struct {
+	basicResponseWriter
+	readFromCalled bool
+}
+
+func (r *readerFromResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	r.readFromCalled = true
+	b, err := io.ReadAll(src)
+	r.written = append(r.written, b...)
+	return int64(len(b)), err
+}
+
+type pusherResponseWriter struct {
+	basicResponseWriter
+	pushedTarget string
+}
+
+func (p *pusherResponseWriter) Push(target string, opts *http.PushOptions) error {
+	p.pushedTarget = target
+	return nil
+}
+
+type deadlineResponseWriter struct {
+	basicResponseWriter
+	writeDeadline time.Time
+}
+
+func (d *deadlineResponseWriter) SetWriteDeadline(t time.Time) error {
+	d.writeDeadline = t
+	return nil
+}
+
+func TestResponseCaptureOptionalInterfaces(t *testing.T) {
+	Convey("responseCapture implements io.ReaderFrom", t, func() {
+		Convey("When the underlying writer implements io.ReaderFrom, its ReadFrom is used", func() {
+			rw := &readerFromResponseWriter{}
+			r := &responseCapture{ResponseWriter: rw}
+			n, err := r.ReadFrom(strings.NewReader("abcdef"))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 6)
+			So(rw.readFromCalled, ShouldBeTrue)
+			So(string(rw.written), ShouldEqual, "abcdef")
+			So(r.bytesWritten, ShouldEqual, 6)
+			So(*r.statusCode, ShouldEqual, 200)
+		})
+
+		Convey("When the underlying writer doesn't implement io.ReaderFrom, Write is used", func() {
+			rw := &basicResponseWriter{}
+			r := &responseCapture{ResponseWriter: rw}
+			n, err := r.ReadFrom(strings.NewReader("abcdef"))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 6)
+			So(string(rw.written), ShouldEqual, "abcdef")
+			So(r.bytesWritten, ShouldEqual, 6)
+		})
+
+		Convey("When the response body is being captured, Write is used", func() {
+			rw := &readerFromResponseWriter{}
+			rw.Header().Set("Content-Type", "text/plain")
+			r := &responseCapture{ResponseWriter: rw, bodyCapture: &BodyCapture{}}
+			_, err := r.ReadFrom(strings.NewReader("abcdef"))
+			So(err, ShouldBeNil)
+			So(rw.readFromCalled, ShouldBeFalse)
+			So(r.bytesWritten, ShouldEqual, 6)
+			So(r.body.eventBody().Content, ShouldEqual, "abcdef")
+		})
+	})
+
+	Convey("responseCapture implements http.Pusher", t, func() {
+		Convey("When the underlying writer implements http.Pusher, Push is forwarded", func() {
+			rw := &pusherResponseWriter{}
+			r := &responseCapture{ResponseWriter: rw}
+			So(r, ShouldImplement, (*http.Pusher)(nil))
+			So(r.Push("/style.css", nil), ShouldBeNil)
+			So(rw.pushedTarget, ShouldEqual, "/style.css")
+		})
+
+		Convey("When the underlying writer doesn't implement http.Pusher, http.ErrNotSupported is returned", func() {
+			r := &responseCapture{ResponseWriter: &basicResponseWriter{}}
+			So(r.Push("/style.css", nil), ShouldEqual, http.ErrNotSupported)
+		})
+	})
+
+	Convey("responseCapture works with http.ResponseController", t, func() {
+		Convey("Unwrap returns the underlying writer", func() {
+			rw := &basicResponseWriter{}
+			r := &responseCapture{ResponseWriter: rw}
+			So(r.Unwrap(), ShouldEqual, rw)
+		})
+
+		Convey("SetWriteDeadline reaches the underlying writer", func() {
+			rw := &deadlineResponseWriter{}
+			r := &responseCapture{ResponseWriter: rw}
+			deadline := time.Now().Add(time.Minute)
+			So(http.NewResponseController(r).SetWriteDeadline(deadline), ShouldBeNil)
+			So(rw.writeDeadline, ShouldEqual, deadline)
+		})
+
+		Convey("SetWriteDeadline returns http.ErrNotSupported if the underlying writer doesn't support it", func() {
+			r := &responseCapture{ResponseWriter: &basicResponseWriter{}}
+			err := http.NewResponseController(r).SetWriteDeadline(time.Now())
+			So(errors.Is(err, http.ErrNotSupported), ShouldBeTrue)
+		})
+
+		Convey("Flush reaches the underlying writer", func() {
+			rw := &responseWriter{}
+			r := &responseCapture{ResponseWriter: rw}
+			So(http.NewResponseController(r).Flush(), ShouldBeNil)
+			So(rw.flushCalled, ShouldBeTrue)
+			So(r.flushCount, ShouldEqual, 1)
+		})
+
+		Convey("Flush returns http.ErrNotSupported if the underlying writer can't flush", func() {
+			r := &responseCapture{ResponseWriter: &basicResponseWriter{}}
+			err := http.NewResponseController(r).Flush()
+			So(errors.Is(err, http.ErrNotSupported), ShouldBeTrue)
+
+			Convey("And the flush isn't counted", func() {
+				r.Flush()
+				So(r.flushCount, ShouldEqual, 0)
+				So(r.headersWrittenAt, ShouldBeNil)
+			})
+		})
+
+		Convey("Hijack returns http.ErrNotSupported if the underlying writer can't hijack", func() {
+			r := &responseCapture{ResponseWriter: &basicResponseWriter{}}
+			_, _, err := http.NewResponseController(r).Hijack()
+			So(errors.Is(err, http.ErrNotSupported), ShouldBeTrue)
+		})
+	})
+}
+
+func TestMiddlewareStreamingData(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("Given a middleware wrapping a streaming handler", t, func() {
+		var completed *EventHTTP
+		mock.onEvent = func(e eventFuncMock) {
+			if e.capEvent == "http request completed" {
+				completed = e.capOpts[0].(*EventHTTP)
+			}
+		}
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+			time.Sleep(10 * time.Millisecond)
+			for i := 0; i < 3; i++ {
+				w.Write([]byte("chunk"))
+				w.(http.Flusher).Flush()
+			}
+		})
+
+		Convey("When the request completes", func() {
+			Middleware(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+
+			Convey("Then the streaming data is included in the completed event", func() {
+				So(completed, ShouldNotBeNil)
+				So(completed.TimeToHeaders, ShouldNotBeNil)
+				So(*completed.TimeToHeaders, ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+				So(completed.TimeToFirstByte, ShouldNotBeNil)
+				So(*completed.TimeToFirstByte, ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+				So(*completed.TimeToFirstByte, ShouldBeLessThanOrEqualTo, *completed.Duration)
+				So(completed.FlushCount, ShouldEqual, 3)
+				So(completed.ClientDisconnected, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the client disconnects before the request completes", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			req := httptest.NewRequest("GET", "/", http.NoBody).WithContext(ctx)
+			Middleware(h).ServeHTTP(httptest.NewRecorder(), req)
+
+			Convey("Then the completed event records the disconnect", func() {
+				So(completed.ClientDisconnected, ShouldBeTrue)
+			})
+		})
+
+		Convey("When the request times out before it completes", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+			req := httptest.NewRequest("GET", "/", http.NoBody).WithContext(ctx)
+			Middleware(h).ServeHTTP(httptest.NewRecorder(), req)
+
+			Convey("Then the completed event doesn't record a disconnect", func() {
+				So(completed.ClientDisconnected, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a middleware wrapping a handler which writes nothing", t, func() {
+		var completed *EventHTTP
+		mock.onEvent = func(e eventFuncMock) {
+			if e.capEvent == "http request completed" {
+				completed = e.capOpts[0].(*EventHTTP)
+			}
+		}
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+		Middleware(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+
+		Convey("Then the timings are not included", func() {
+			So(completed.TimeToHeaders, ShouldBeNil)
+			So(completed.TimeToFirstByte, ShouldBeNil)
+			So(completed.FlushCount, ShouldEqual, 0)
+		})
+	})
+}
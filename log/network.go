@@ -0,0 +1,630 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultNetworkBatchCount is the maximum number of events in a batch
+	DefaultNetworkBatchCount = 100
+	// DefaultNetworkBatchBytes is the maximum size of a batch in bytes
+	DefaultNetworkBatchBytes = 1 << 20
+	// DefaultNetworkBatchInterval is the maximum time an event waits to be sent
+	DefaultNetworkBatchInterval = time.Second
+	// DefaultNetworkRetries is the number of times a batch is retried
+	DefaultNetworkRetries = 5
+	// DefaultNetworkBackoff is the delay before the first retry
+	DefaultNetworkBackoff = 100 * time.Millisecond
+	// DefaultNetworkMaxBackoff is the maximum delay between retries
+	DefaultNetworkMaxBackoff = 30 * time.Second
+	// DefaultNetworkMaxPendingBytes is the size of the events waiting to be
+	// sent, after which they are spilled to disk or dropped
+	DefaultNetworkMaxPendingBytes = 16 << 20
+	// DefaultNetworkMaxSpillBytes is the size of the spill directory, after
+	// which the oldest spilled batches are dropped
+	DefaultNetworkMaxSpillBytes = 256 << 20
+	// DefaultNetworkTimeout is the timeout for connecting and sending a batch
+	DefaultNetworkTimeout = 10 * time.Second
+)
+
+// ErrWriterClosed is returned when writing to a writer which has been closed
+var ErrWriterClosed = errors.New("log writer is closed")
+
+// NetworkOptions configures a NetworkWriter. Zero values are replaced with
+// the defaults above.
+type NetworkOptions struct {
+	// A batch is sent when it contains BatchCount events or BatchBytes bytes,
+	// or BatchInterval after the previous batch was sent
+	BatchCount    int
+	BatchBytes    int
+	BatchInterval time.Duration
+
+	// Retries is the number of times a batch is retried, with a delay starting
+	// at Backoff which doubles after each retry up to MaxBackoff
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// SpillDir is a directory where batches which couldn't be sent are saved,
+	// and sent when the collector is available again. They are dropped if
+	// it's empty.
+	SpillDir string
+
+	// MaxSpillBytes limits the size of the batches in SpillDir, after which
+	// the oldest are dropped
+	MaxSpillBytes int64
+
+	// MaxPendingBytes limits the memory used by events waiting to be sent
+	MaxPendingBytes int
+
+	// Timeout for connecting and sending a batch
+	Timeout time.Duration
+
+	// TLSConfig enables TLS for TCP connections
+	TLSConfig *tls.Config
+
+	// Gzip compresses HTTP request bodies
+	Gzip bool
+
+	// Header is added to HTTP requests, e.g. for authentication
+	Header http.Header
+}
+
+func (o NetworkOptions) withDefaults() NetworkOptions {
+	if o.BatchCount <= 0 {
+		o.BatchCount = DefaultNetworkBatchCount
+	}
+	if o.BatchBytes <= 0 {
+		o.BatchBytes = DefaultNetworkBatchBytes
+	}
+	if o.BatchInterval <= 0 {
+		o.BatchInterval = DefaultNetworkBatchInterval
+	}
+	if o.Retries <= 0 {
+		o.Retries = DefaultNetworkRetries
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = DefaultNetworkBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultNetworkMaxBackoff
+	}
+	if o.MaxPendingBytes <= 0 {
+		o.MaxPendingBytes = DefaultNetworkMaxPendingBytes
+	}
+	if o.MaxSpillBytes <= 0 {
+		o.MaxSpillBytes = DefaultNetworkMaxSpillBytes
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultNetworkTimeout
+	}
+	return o
+}
+
+// batchSender sends a batch of newline terminated events
+type batchSender interface {
+	send(lines [][]byte) error
+	close() error
+}
+
+// permanentError is returned by a batchSender when a batch shouldn't be retried
+type permanentError struct {
+	error
+}
+
+// partialSendError is returned by a batchSender when only the first sent
+// events in a batch were sent, so only the rest should be retried
+type partialSendError struct {
+	error
+	sent int
+}
+
+// NetworkWriter is an io.WriteCloser which sends events to a collector in
+// batches. Each call to Write must be a single newline terminated event, so
+// it can be used as a destination with SetDestination or AddSink:
+//
+//	w, err := log.NewHTTPWriter("https://collector/logs", log.NetworkOptions{Gzip: true})
+//	if err != nil {
+//		...
+//	}
+//	defer w.Close()
+//	sink := log.AddSink(w)
+//	defer sink.Close()
+//
+// Events are sent from a separate goroutine, and Close sends any events
+// which are waiting, so it should be called after the sink is closed. It is
+// safe for concurrent use.
+type NetworkWriter struct {
+	opts   NetworkOptions
+	sender batchSender
+
+	mu           sync.Mutex
+	pending      [][]byte
+	pendingBytes int
+	closed       bool
+	spillSeq     int
+
+	full    chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+
+	sent, dropped, spilled atomic.Int64
+}
+
+// NewTCPWriter returns a NetworkWriter which sends newline delimited JSON
+// over a TCP connection, using TLS if opts.TLSConfig is set
+func NewTCPWriter(address string, opts NetworkOptions) (*NetworkWriter, error) {
+	opts = opts.withDefaults()
+	return newNetworkWriter(&tcpSender{address: address, tlsConfig: opts.TLSConfig, timeout: opts.Timeout}, opts)
+}
+
+// NewUDPWriter returns a NetworkWriter which sends each event as a UDP datagram
+func NewUDPWriter(address string, opts NetworkOptions) (*NetworkWriter, error) {
+	opts = opts.withDefaults()
+	conn, err := net.DialTimeout("udp", address, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newNetworkWriter(&udpSender{conn: conn}, opts)
+}
+
+// NewHTTPWriter returns a NetworkWriter which POSTs batches of newline
+// delimited JSON to url. Responses other than 429 and 5xx are not retried.
+func NewHTTPWriter(url string, opts NetworkOptions) (*NetworkWriter, error) {
+	opts = opts.withDefaults()
+	sender, err := newHTTPSender(url, "application/x-ndjson", opts)
+	if err != nil {
+		return nil, err
+	}
+	return newNetworkWriter(sender, opts)
+}
+
+func newHTTPSender(url, contentType string, opts NetworkOptions) (*httpSender, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid collector url: %q", url)
+	}
+	return &httpSender{
+		url:         url,
+		client:      &http.Client{Timeout: opts.Timeout},
+		header:      opts.Header,
+		gzip:        opts.Gzip,
+		contentType: contentType,
+	}, nil
+}
+
+func newNetworkWriter(sender batchSender, opts NetworkOptions) (*NetworkWriter, error) {
+	if opts.SpillDir != "" {
+		if err := os.MkdirAll(opts.SpillDir, 0o750); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &NetworkWriter{
+		opts:    opts,
+		sender:  sender,
+		full:    make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write adds an event to the next batch
+func (w *NetworkWriter) Write(b []byte) (int, error) {
+	line := make([]byte, len(b))
+	copy(line, b)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	if w.pendingBytes+len(line) > w.opts.MaxPendingBytes {
+		// the collector has been unavailable for a while, so make room
+		w.spillOrDrop(w.pending)
+		w.pending = nil
+		w.pendingBytes = 0
+	}
+
+	w.pending = append(w.pending, line)
+	w.pendingBytes += len(line)
+
+	if len(w.pending) >= w.opts.BatchCount || w.pendingBytes >= w.opts.BatchBytes {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+	return len(b), nil
+}
+
+// Close sends any events which are waiting, and closes the connection.
+// Events which can't be sent are spilled to disk or dropped.
+func (w *NetworkWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.closing)
+	<-w.done
+	return w.sender.close()
+}
+
+// Sent returns the number of events which have been sent
+func (w *NetworkWriter) Sent() int64 {
+	return w.sent.Load()
+}
+
+// Dropped returns the number of events which couldn't be sent or spilled
+func (w *NetworkWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Spilled returns the number of events which have been spilled to disk
+func (w *NetworkWriter) Spilled() int64 {
+	return w.spilled.Load()
+}
+
+// run sends batches until the writer is closed
+func (w *NetworkWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.BatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flush(false, false)
+		case <-w.full:
+			w.flush(false, true)
+		case <-w.closing:
+			w.flush(true, false)
+			return
+		}
+	}
+}
+
+// flush sends spilled batches and then pending events, or only full batches
+// of pending events if onlyFull is true. If the collector is unavailable,
+// events are left pending to be retried later, unless closing.
+func (w *NetworkWriter) flush(closing, onlyFull bool) {
+	collectorAvailable := w.sendSpilled()
+
+	for {
+		batch := w.nextBatch(onlyFull)
+		if len(batch) == 0 {
+			return
+		}
+
+		if collectorAvailable {
+			var err error
+			if batch, err = w.sendWithRetry(batch); err == nil {
+				continue
+			}
+			collectorAvailable = false
+		}
+
+		w.mu.Lock()
+		w.spillOrDrop(batch)
+		w.mu.Unlock()
+
+		if !closing {
+			return
+		}
+	}
+}
+
+// batchLength returns the number of events at the start of lines which fit
+// in a batch, and their size
+func (w *NetworkWriter) batchLength(lines [][]byte) (n, size int) {
+	for n < len(lines) && n < w.opts.BatchCount {
+		if n > 0 && size+len(lines[n]) > w.opts.BatchBytes {
+			break
+		}
+		size += len(lines[n])
+		n++
+	}
+	return n, size
+}
+
+// nextBatch removes the next batch from the pending events, or returns nil
+// if onlyFull is true and there isn't a full batch
+func (w *NetworkWriter) nextBatch(onlyFull bool) [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, size := w.batchLength(w.pending)
+
+	if onlyFull && n == len(w.pending) && n < w.opts.BatchCount && size < w.opts.BatchBytes {
+		return nil
+	}
+
+	batch := w.pending[:n:n]
+	w.pending = w.pending[n:]
+	w.pendingBytes -= size
+	return batch
+}
+
+// sendWithRetry sends a batch, retrying with exponential backoff. It returns
+// the events which weren't sent if it fails.
+func (w *NetworkWriter) sendWithRetry(batch [][]byte) ([][]byte, error) {
+	backoff := w.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		err := w.sender.send(batch)
+		if err == nil {
+			w.sent.Add(int64(len(batch)))
+			return nil, nil
+		}
+		batch = w.removeSent(batch, err)
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= w.opts.Retries {
+			return batch, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > w.opts.MaxBackoff {
+			backoff = w.opts.MaxBackoff
+		}
+	}
+}
+
+// removeSent counts the events which were sent if err is a partialSendError,
+// and returns the rest of the batch
+func (w *NetworkWriter) removeSent(batch [][]byte, err error) [][]byte {
+	var partial partialSendError
+	if !errors.As(err, &partial) {
+		return batch
+	}
+	w.sent.Add(int64(partial.sent))
+	return batch[partial.sent:]
+}
+
+// spillOrDrop saves events to the spill directory, in files of at most one
+// batch so that each can be sent as a batch, or drops them if there isn't
+// one. w.mu must be held.
+func (w *NetworkWriter) spillOrDrop(lines [][]byte) {
+	if len(lines) == 0 {
+		return
+	}
+
+	if w.opts.SpillDir == "" {
+		w.dropped.Add(int64(len(lines)))
+		return
+	}
+
+	for len(lines) > 0 {
+		n, _ := w.batchLength(lines)
+		w.spillSeq++
+		name := fmt.Sprintf("log-%020d-%06d.ndjson", time.Now().UnixNano(), w.spillSeq)
+		if err := os.WriteFile(filepath.Join(w.opts.SpillDir, name), bytes.Join(lines[:n], nil), 0o640); err == nil {
+			w.spilled.Add(int64(n))
+		} else {
+			w.dropped.Add(int64(n))
+		}
+		lines = lines[n:]
+	}
+
+	w.removeOldSpills()
+}
+
+// spillFiles returns the spilled batches, oldest first
+func (w *NetworkWriter) spillFiles() []string {
+	files, err := filepath.Glob(filepath.Join(w.opts.SpillDir, "log-*.ndjson"))
+	if err != nil {
+		return nil
+	}
+	// the timestamp and sequence number sort in the order they were spilled
+	sort.Strings(files)
+	return files
+}
+
+// removeOldSpills drops the oldest spilled batches until the spill directory
+// is no larger than MaxSpillBytes
+func (w *NetworkWriter) removeOldSpills() {
+	files := w.spillFiles()
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+
+	for i := 0; i < len(files) && total > w.opts.MaxSpillBytes; i++ {
+		b, err := os.ReadFile(files[i])
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(files[i]); err != nil {
+			continue
+		}
+		w.dropped.Add(int64(bytes.Count(b, []byte("\n"))))
+		total -= sizes[i]
+	}
+}
+
+// sendSpilled sends spilled batches, oldest first, and returns false if one
+// couldn't be sent
+func (w *NetworkWriter) sendSpilled() bool {
+	if w.opts.SpillDir == "" {
+		return true
+	}
+
+	for _, f := range w.spillFiles() {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		batch := bytes.SplitAfter(b, []byte("\n"))
+		if len(batch[len(batch)-1]) == 0 {
+			batch = batch[:len(batch)-1]
+		}
+
+		// spilled batches aren't retried, they're left for the next flush
+		if err := w.sender.send(batch); err != nil {
+			var permanent permanentError
+			if !errors.As(err, &permanent) {
+				if rest := w.removeSent(batch, err); len(rest) < len(batch) {
+					// keep only the events which weren't sent
+					os.WriteFile(f, bytes.Join(rest, nil), 0o640) //nolint:errcheck // the whole batch is resent if this fails
+				}
+				return false
+			}
+			w.dropped.Add(int64(len(batch)))
+		} else {
+			w.sent.Add(int64(len(batch)))
+		}
+		os.Remove(f)
+	}
+	return true
+}
+
+// tcpSender sends batches over a TCP connection, reconnecting if it fails
+type tcpSender struct {
+	address   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	conn      net.Conn
+}
+
+func (s *tcpSender) send(lines [][]byte) error {
+	if s.conn == nil {
+		dialer := &net.Dialer{Timeout: s.timeout}
+		var err error
+		if s.tlsConfig != nil {
+			s.conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+		} else {
+			s.conn, err = dialer.Dial("tcp", s.address)
+		}
+		if err != nil {
+			s.conn = nil
+			return err
+		}
+	}
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	if _, err := writeAll(s.conn, bytes.Join(lines, nil)); err != nil {
+		// part of the batch may have been sent, so events can be duplicated
+		// when it is retried
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *tcpSender) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// udpSender sends each event as a datagram
+type udpSender struct {
+	conn net.Conn
+}
+
+func (s *udpSender) send(lines [][]byte) error {
+	for i, line := range lines {
+		if _, err := s.conn.Write(line); err != nil {
+			// don't resend the events which were sent
+			if i > 0 {
+				return partialSendError{err, i}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *udpSender) close() error {
+	return s.conn.Close()
+}
+
+// httpSender POSTs batches to a collector
+type httpSender struct {
+	url         string
+	client      *http.Client
+	header      http.Header
+	gzip        bool
+	contentType string
+}
+
+func (s *httpSender) send(lines [][]byte) error {
+	return s.post(bytes.Join(lines, nil))
+}
+
+// post sends b as the request body
+func (s *httpSender) post(b []byte) error {
+	var body bytes.Buffer
+	if s.gzip {
+		gw := gzip.NewWriter(&body)
+		if _, err := gw.Write(b); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+	} else {
+		body.Write(b)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		return permanentError{err}
+	}
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return permanentError{fmt.Errorf("collector returned status %d", resp.StatusCode)}
+}
+
+func (s *httpSender) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
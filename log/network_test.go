@@ -0,0 +1,318 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// collector is an HTTP handler which records the events in each batch
+type collector struct {
+	mu       sync.Mutex
+	batches  [][]string
+	status   int
+	encoding string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+		return
+	}
+
+	var body io.Reader = req.Body
+	c.encoding = req.Header.Get("Content-Encoding")
+	if c.encoding == "gzip" {
+		gr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = gr
+	}
+	b, _ := io.ReadAll(body)
+	c.batches = append(c.batches, strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"))
+}
+
+func (c *collector) setStatus(status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status = status
+}
+
+func (c *collector) received() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]string(nil), c.batches...)
+}
+
+func TestHTTPWriter(t *testing.T) {
+	Convey("Given a collector", t, func() {
+		c := &collector{}
+		srv := httptest.NewServer(c)
+		defer srv.Close()
+
+		Convey("Events are sent in batches of BatchCount", func() {
+			w, err := NewHTTPWriter(srv.URL, NetworkOptions{BatchCount: 2, BatchInterval: time.Hour, Gzip: true})
+			So(err, ShouldBeNil)
+
+			for _, e := range []string{"1", "2", "3"} {
+				w.Write([]byte(e + "\n"))
+			}
+			So(waitFor(func() bool { return len(c.received()) == 1 }), ShouldBeTrue)
+			So(c.received()[0], ShouldResemble, []string{"1", "2"})
+			So(c.encoding, ShouldEqual, "gzip")
+
+			Convey("And remaining events are sent on Close", func() {
+				So(w.Close(), ShouldBeNil)
+				So(c.received(), ShouldResemble, [][]string{{"1", "2"}, {"3"}})
+				So(w.Sent(), ShouldEqual, 3)
+
+				_, err := w.Write([]byte("4\n"))
+				So(err, ShouldEqual, ErrWriterClosed)
+			})
+		})
+
+		Convey("Events are sent in batches of at most BatchBytes", func() {
+			w, err := NewHTTPWriter(srv.URL, NetworkOptions{BatchBytes: 4, BatchInterval: time.Hour})
+			So(err, ShouldBeNil)
+
+			for _, e := range []string{"1", "2", "3"} {
+				w.Write([]byte(e + "\n"))
+			}
+			So(w.Close(), ShouldBeNil)
+			So(c.received(), ShouldResemble, [][]string{{"1", "2"}, {"3"}})
+		})
+
+		Convey("Events are sent after BatchInterval", func() {
+			w, err := NewHTTPWriter(srv.URL, NetworkOptions{BatchInterval: 10 * time.Millisecond})
+			So(err, ShouldBeNil)
+			defer w.Close()
+
+			w.Write([]byte("1\n"))
+			So(waitFor(func() bool { return len(c.received()) == 1 }), ShouldBeTrue)
+		})
+
+		Convey("Batches are retried if the collector returns an error", func() {
+			c.setStatus(http.StatusServiceUnavailable)
+			w, err := NewHTTPWriter(srv.URL, NetworkOptions{BatchInterval: time.Hour, Retries: 5, Backoff: 5 * time.Millisecond})
+			So(err, ShouldBeNil)
+
+			w.Write([]byte("1\n"))
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				c.setStatus(0)
+			}()
+			So(w.Close(), ShouldBeNil)
+			So(c.received(), ShouldResemble, [][]string{{"1"}})
+			So(w.Dropped(), ShouldEqual, 0)
+		})
+
+		Convey("Batches are dropped if the collector rejects them", func() {
+			c.setStatus(http.StatusBadRequest)
+			w, err := NewHTTPWriter(srv.URL, NetworkOptions{BatchInterval: time.Hour, Backoff: time.Hour})
+			So(err, ShouldBeNil)
+
+			w.Write([]byte("1\n"))
+			So(w.Close(), ShouldBeNil)
+			So(w.Dropped(), ShouldEqual, 1)
+		})
+
+		Convey("Given a spill directory and the collector is unavailable", func() {
+			dir := t.TempDir()
+			c.setStatus(http.StatusServiceUnavailable)
+
+			w, err := NewHTTPWriter(srv.URL, NetworkOptions{BatchInterval: time.Hour, Retries: 1, Backoff: time.Millisecond, SpillDir: dir})
+			So(err, ShouldBeNil)
+			w.Write([]byte("1\n"))
+			w.Write([]byte("2\n"))
+			So(w.Close(), ShouldBeNil)
+
+			Convey("Events are spilled to disk", func() {
+				So(w.Spilled(), ShouldEqual, 2)
+				files, _ := os.ReadDir(dir)
+				So(files, ShouldHaveLength, 1)
+
+				Convey("And sent before new events when the collector is available", func() {
+					c.setStatus(0)
+					w, err := NewHTTPWriter(srv.URL, NetworkOptions{BatchInterval: time.Hour, SpillDir: dir})
+					So(err, ShouldBeNil)
+					w.Write([]byte("3\n"))
+					So(w.Close(), ShouldBeNil)
+
+					So(c.received(), ShouldResemble, [][]string{{"1", "2"}, {"3"}})
+					files, _ := os.ReadDir(dir)
+					So(files, ShouldBeEmpty)
+				})
+			})
+		})
+
+		Convey("Given a spill directory with a size limit and the collector is unavailable", func() {
+			dir := t.TempDir()
+			c.setStatus(http.StatusServiceUnavailable)
+
+			w, err := NewHTTPWriter(srv.URL, NetworkOptions{
+				BatchCount: 2, BatchInterval: time.Hour, Retries: 1, Backoff: time.Millisecond,
+				SpillDir: dir, MaxSpillBytes: 6,
+			})
+			So(err, ShouldBeNil)
+			for _, e := range []string{"1\n", "2\n", "3\n", "4\n", "5\n"} {
+				w.Write([]byte(e))
+			}
+			So(w.Close(), ShouldBeNil)
+
+			Convey("Events are spilled in batches, and the oldest are dropped", func() {
+				So(w.Spilled(), ShouldEqual, 5)
+				So(w.Dropped(), ShouldEqual, 2)
+
+				files, _ := os.ReadDir(dir)
+				var spilled []string
+				for _, f := range files {
+					spilled = append(spilled, readFile(filepath.Join(dir, f.Name())))
+				}
+				So(spilled, ShouldResemble, []string{"3\n4\n", "5\n"})
+			})
+		})
+
+		Convey("Spilled events are split into batches", func() {
+			dir := t.TempDir()
+			w := &NetworkWriter{opts: NetworkOptions{BatchCount: 2, SpillDir: dir}.withDefaults()}
+
+			w.spillOrDrop([][]byte{[]byte("1\n"), []byte("2\n"), []byte("3\n")})
+
+			files, _ := os.ReadDir(dir)
+			So(files, ShouldHaveLength, 2)
+			So(readFile(filepath.Join(dir, files[0].Name())), ShouldEqual, "1\n2\n")
+			So(readFile(filepath.Join(dir, files[1].Name())), ShouldEqual, "3\n")
+			So(w.Spilled(), ShouldEqual, 3)
+		})
+	})
+
+	Convey("NewHTTPWriter returns an error for an invalid url", t, func() {
+		_, err := NewHTTPWriter("localhost:1234", NetworkOptions{})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestTCPWriter(t *testing.T) {
+	Convey("Given a TCP listener", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer l.Close()
+
+		lines := make(chan string, 10)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			close(lines)
+		}()
+
+		Convey("Events are sent as newline delimited JSON", func() {
+			w, err := NewTCPWriter(l.Addr().String(), NetworkOptions{BatchInterval: time.Hour})
+			So(err, ShouldBeNil)
+
+			w.Write([]byte(`{"event":"one"}` + "\n"))
+			w.Write([]byte(`{"event":"two"}` + "\n"))
+			So(w.Close(), ShouldBeNil)
+
+			var received []string
+			for line := range lines {
+				received = append(received, line)
+			}
+			So(received, ShouldResemble, []string{`{"event":"one"}`, `{"event":"two"}`})
+		})
+	})
+}
+
+func TestUDPWriter(t *testing.T) {
+	Convey("Given a UDP listener", t, func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		Convey("Each event is sent as a datagram", func() {
+			w, err := NewUDPWriter(conn.LocalAddr().String(), NetworkOptions{BatchInterval: time.Hour})
+			So(err, ShouldBeNil)
+
+			w.Write([]byte("one\n"))
+			w.Write([]byte("two\n"))
+			So(w.Close(), ShouldBeNil)
+
+			b := make([]byte, 100)
+			So(conn.SetReadDeadline(time.Now().Add(5*time.Second)), ShouldBeNil)
+			n, _, err := conn.ReadFrom(b)
+			So(err, ShouldBeNil)
+			So(string(b[:n]), ShouldEqual, "one\n")
+			n, _, err = conn.ReadFrom(b)
+			So(err, ShouldBeNil)
+			So(string(b[:n]), ShouldEqual, "two\n")
+		})
+	})
+}
+
+// partialConn is a net.Conn which fails after the first write
+type partialConn struct {
+	net.Conn
+	writes int
+}
+
+func (c *partialConn) Write(b []byte) (int, error) {
+	c.writes++
+	if c.writes > 1 {
+		return 0, errors.New("write failed")
+	}
+	return len(b), nil
+}
+
+func (c *partialConn) Close() error { return nil }
+
+func TestUDPWriterPartialSend(t *testing.T) {
+	Convey("Given a UDP connection which fails after sending an event", t, func() {
+		w, err := newNetworkWriter(&udpSender{conn: &partialConn{}}, NetworkOptions{BatchInterval: time.Hour, Retries: 1, Backoff: time.Millisecond}.withDefaults())
+		So(err, ShouldBeNil)
+
+		w.Write([]byte("one\n"))
+		w.Write([]byte("two\n"))
+		w.Write([]byte("three\n"))
+		So(w.Close(), ShouldBeNil)
+
+		Convey("Only the events which weren't sent are dropped", func() {
+			So(w.Sent(), ShouldEqual, 1)
+			So(w.Dropped(), ShouldEqual, 2)
+		})
+	})
+}
+
+// waitFor returns true when f returns true, or false after a timeout
+func waitFor(f func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if f() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultOTLPEndpoint is the OTLP/HTTP logs endpoint of a local collector
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/logs"
+
+// otlpScopeName is the instrumentation scope of exported log records
+const otlpScopeName = "github.com/ONSdigital/log.go/v2/log"
+
+// OTLPEncoder encodes an event as an OTLP/HTTP JSON ExportLogsServiceRequest
+// containing a single log record, which is the OTLP JSON file format.
+//
+// The namespace is the service.name resource attribute, Data is included as
+// attributes, and the first error is included as exception.* attributes.
+// A trace ID which isn't a valid W3C trace ID, e.g. a request ID, is included
+// as the request_id attribute.
+var OTLPEncoder Encoder = encodeOTLP
+
+// NewOTLPWriter returns a NetworkWriter which sends batches of events
+// encoded with OTLPEncoder to an OTLP/HTTP logs endpoint. If the endpoint is
+// empty, the OTEL_EXPORTER_OTLP_LOGS_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT
+// environment variables are used, or DefaultOTLPEndpoint if neither is set.
+func NewOTLPWriter(endpoint string, opts NetworkOptions) (*NetworkWriter, error) {
+	opts = opts.withDefaults()
+	sender, err := newHTTPSender(otlpEndpoint(endpoint), "application/json", opts)
+	if err != nil {
+		return nil, err
+	}
+	return newNetworkWriter(&otlpSender{sender}, opts)
+}
+
+// AddOTLPSink registers a sink which exports events to an OTLP/HTTP logs
+// endpoint, see NewOTLPWriter
+func AddOTLPSink(endpoint string, opts NetworkOptions, sinkOpts ...SinkOption) (*Sink, error) {
+	w, err := NewOTLPWriter(endpoint, opts)
+	if err != nil {
+		return nil, err
+	}
+	return AddSink(w, append([]SinkOption{SinkEncoder(OTLPEncoder), sinkClosesWriter()}, sinkOpts...)...), nil
+}
+
+func otlpEndpoint(endpoint string) string {
+	if endpoint != "" {
+		return endpoint
+	}
+	if e := os.Getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"); e != "" {
+		return e
+	}
+	if e := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); e != "" {
+		return strings.TrimSuffix(e, "/") + "/v1/logs"
+	}
+	return DefaultOTLPEndpoint
+}
+
+// The types below are the subset of the OTLP JSON encoding used for logs.
+// 64 bit integers are encoded as strings, and trace and span IDs as hex.
+
+type otlpLogsData struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	EventName            string         `json:"eventName,omitempty"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvlist     `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKvlist struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// otlpSeverityNumber maps a severity to the first OTLP severity number in
+// the corresponding range
+func otlpSeverityNumber(s severity) int {
+	switch s {
+	case FATAL:
+		return 21
+	case ERROR:
+		return 17
+	case WARN:
+		return 13
+	case INFO:
+		return 9
+	case DEBUG:
+		return 5
+	}
+	return 1
+}
+
+// encodeOTLP implements OTLPEncoder
+func encodeOTLP(e EventData) ([]byte, error) {
+	ts := strconv.FormatInt(e.CreatedAt.UnixNano(), 10)
+	record := otlpLogRecord{
+		TimeUnixNano:         ts,
+		ObservedTimeUnixNano: ts,
+		EventName:            e.Event,
+		Body:                 otlpString(e.Event),
+	}
+
+	if e.Severity != nil {
+		record.SeverityNumber = otlpSeverityNumber(*e.Severity)
+		record.SeverityText = e.Severity.name()
+	}
+
+	attr := func(k string, v otlpAnyValue) {
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: k, Value: v})
+	}
+
+	if traceID, err := trace.TraceIDFromHex(e.TraceID); err == nil {
+		record.TraceID = traceID.String()
+		if spanID, err := trace.SpanIDFromHex(e.SpanID); err == nil {
+			record.SpanID = spanID.String()
+		}
+	} else if e.TraceID != "" {
+		attr("request_id", otlpString(e.TraceID))
+	}
+
+	if e.HTTP != nil {
+		attr("http.request.method", otlpString(e.HTTP.Method))
+		attr("url.path", otlpString(e.HTTP.Path))
+		if e.HTTP.StatusCode != nil && *e.HTTP.StatusCode != 0 {
+			attr("http.response.status_code", otlpValue(*e.HTTP.StatusCode))
+		}
+	}
+	if e.Auth != nil && e.Auth.Identity != "" {
+		attr("enduser.id", otlpString(e.Auth.Identity))
+	}
+
+	if e.Errors != nil && len(*e.Errors) > 0 {
+		errs := *e.Errors
+		attr("exception.message", otlpString(errs[0].Message))
+		if len(errs[0].StackTrace) > 0 {
+			attr("exception.stacktrace", otlpString(formatStackTrace(errs[0].StackTrace)))
+		}
+		if len(errs) > 1 {
+			messages := make([]interface{}, 0, len(errs))
+			for _, err := range errs {
+				messages = append(messages, err.Message)
+			}
+			attr("errors", otlpValue(messages))
+		}
+	}
+
+	if e.Data != nil {
+		keys := make([]string, 0, len(*e.Data))
+		for k := range *e.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			attr(k, otlpValue((*e.Data)[k]))
+		}
+	}
+
+	return json.Marshal(otlpLogsData{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{{Key: "service.name", Value: otlpString(e.Namespace)}}},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: otlpScopeName},
+			LogRecords: []otlpLogRecord{record},
+		}},
+	}}})
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpValue converts v to an OTLP value. Types other than strings, numbers,
+// booleans, slices and maps are converted using their JSON encoding.
+func otlpValue(v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case nil:
+		return otlpAnyValue{}
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(v)
+		return otlpAnyValue{IntValue: &s}
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			s := v.String()
+			return otlpAnyValue{IntValue: &s}
+		}
+		f, _ := v.Float64()
+		return otlpAnyValue{DoubleValue: &f}
+	case []interface{}:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, item := range v {
+			values = append(values, otlpValue(item))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]otlpKeyValue, 0, len(v))
+		for _, k := range keys {
+			values = append(values, otlpKeyValue{Key: k, Value: otlpValue(v[k])})
+		}
+		return otlpAnyValue{KvlistValue: &otlpKvlist{Values: values}}
+	case fmt.Stringer:
+		return otlpString(v.String())
+	}
+
+	// anything else, e.g. Data or a struct, is converted to its JSON representation
+	b, err := json.Marshal(v)
+	if err != nil {
+		return otlpString(fmt.Sprint(v))
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return otlpString(string(b))
+	}
+	return otlpValue(generic)
+}
+
+// otlpSender merges batches of single record requests into one request,
+// grouping records by resource
+type otlpSender struct {
+	*httpSender
+}
+
+func (s *otlpSender) send(lines [][]byte) error {
+	var merged otlpLogsData
+	resources := map[string]int{}
+
+	for _, line := range lines {
+		var data otlpLogsData
+		if err := json.Unmarshal(line, &data); err != nil {
+			// the writer must only be used with OTLPEncoder
+			return permanentError{fmt.Errorf("invalid OTLP log record: %w", err)}
+		}
+		for _, rl := range data.ResourceLogs {
+			key, _ := json.Marshal(rl.Resource)
+			i, ok := resources[string(key)]
+			if !ok {
+				i = len(merged.ResourceLogs)
+				resources[string(key)] = i
+				merged.ResourceLogs = append(merged.ResourceLogs, otlpResourceLogs{
+					Resource:  rl.Resource,
+					ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otlpScopeName}}},
+				})
+			}
+			scope := &merged.ResourceLogs[i].ScopeLogs[0]
+			for _, sl := range rl.ScopeLogs {
+				scope.LogRecords = append(scope.LogRecords, sl.LogRecords...)
+			}
+		}
+	}
+
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return permanentError{err}
+	}
+	return s.post(b)
+}
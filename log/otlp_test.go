@@ -0,0 +1,157 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOTLPEncoder(t *testing.T) {
+	Convey("Given an event", t, func() {
+		sev := ERROR
+		status := 500
+		e := EventData{
+			CreatedAt: time.Unix(1607599000, 123),
+			Namespace: "my-service",
+			Event:     "request failed",
+			TraceID:   "0102030405060708090a0b0c0d0e0f10",
+			SpanID:    "0102030405060708",
+			Severity:  &sev,
+			HTTP:      &EventHTTP{Method: "GET", Path: "/foo", StatusCode: &status},
+			Data:      &Data{"count": 2, "ok": true, "ratio": 0.5, "tags": []string{"a"}, "nested": Data{"b": 1}},
+			Errors:    FormatErrors([]error{errors.New("first"), errors.New("second")}).(*EventErrors),
+		}
+
+		Convey("It is encoded as an OTLP log record", func() {
+			b, err := OTLPEncoder(e)
+			So(err, ShouldBeNil)
+
+			var data otlpLogsData
+			So(json.Unmarshal(b, &data), ShouldBeNil)
+			So(data.ResourceLogs, ShouldHaveLength, 1)
+			So(*data.ResourceLogs[0].Resource.Attributes[0].Value.StringValue, ShouldEqual, "my-service")
+			So(data.ResourceLogs[0].Resource.Attributes[0].Key, ShouldEqual, "service.name")
+
+			r := data.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+			So(r.TimeUnixNano, ShouldEqual, "1607599000000000123")
+			So(r.SeverityNumber, ShouldEqual, 17)
+			So(r.SeverityText, ShouldEqual, "ERROR")
+			So(*r.Body.StringValue, ShouldEqual, "request failed")
+			So(r.TraceID, ShouldEqual, "0102030405060708090a0b0c0d0e0f10")
+			So(r.SpanID, ShouldEqual, "0102030405060708")
+
+			attrs := map[string]otlpAnyValue{}
+			for _, kv := range r.Attributes {
+				attrs[kv.Key] = kv.Value
+			}
+			So(*attrs["http.request.method"].StringValue, ShouldEqual, "GET")
+			So(*attrs["http.response.status_code"].IntValue, ShouldEqual, "500")
+			So(*attrs["exception.message"].StringValue, ShouldEqual, "first")
+			So(*attrs["exception.stacktrace"].StringValue, ShouldContainSubstring, "otlp_test.go")
+			So(attrs["errors"].ArrayValue.Values, ShouldHaveLength, 2)
+			So(*attrs["count"].IntValue, ShouldEqual, "2")
+			So(*attrs["ok"].BoolValue, ShouldBeTrue)
+			So(*attrs["ratio"].DoubleValue, ShouldEqual, 0.5)
+			So(*attrs["tags"].ArrayValue.Values[0].StringValue, ShouldEqual, "a")
+			So(attrs["nested"].KvlistValue.Values[0].Key, ShouldEqual, "b")
+			So(*attrs["nested"].KvlistValue.Values[0].Value.IntValue, ShouldEqual, "1")
+		})
+
+		Convey("A trace ID which isn't a W3C trace ID is included as an attribute", func() {
+			e.TraceID = "request-123"
+
+			b, err := OTLPEncoder(e)
+			So(err, ShouldBeNil)
+
+			var data otlpLogsData
+			So(json.Unmarshal(b, &data), ShouldBeNil)
+			r := data.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+			So(r.TraceID, ShouldBeEmpty)
+			So(r.SpanID, ShouldBeEmpty)
+			So(r.Attributes[0].Key, ShouldEqual, "request_id")
+			So(*r.Attributes[0].Value.StringValue, ShouldEqual, "request-123")
+		})
+	})
+
+	Convey("Severities are mapped to OTLP severity numbers", t, func() {
+		So(otlpSeverityNumber(FATAL), ShouldEqual, 21)
+		So(otlpSeverityNumber(WARN), ShouldEqual, 13)
+		So(otlpSeverityNumber(INFO), ShouldEqual, 9)
+		So(otlpSeverityNumber(DEBUG), ShouldEqual, 5)
+		So(otlpSeverityNumber(TRACE), ShouldEqual, 1)
+	})
+}
+
+func TestOTLPSink(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	oldNamespace := Namespace
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+		Namespace = oldNamespace
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+	destination = io.Discard
+
+	Convey("Given a stub collector", t, func() {
+		var mu sync.Mutex
+		var requests []otlpLogsData
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if req.URL.Path != "/v1/logs" || req.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var data otlpLogsData
+			if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			requests = append(requests, data)
+		}))
+		defer srv.Close()
+
+		Convey("Events are exported in a single request grouped by service", func() {
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL+"/")
+
+			sink, err := AddOTLPSink("", NetworkOptions{BatchInterval: time.Hour})
+			So(err, ShouldBeNil)
+
+			Namespace = "service-a"
+			Info(context.Background(), "one")
+			Info(context.Background(), "two")
+			Namespace = "service-b"
+			Warn(context.Background(), "three")
+			So(sink.Close(), ShouldBeNil)
+
+			mu.Lock()
+			defer mu.Unlock()
+			So(requests, ShouldHaveLength, 1)
+			So(requests[0].ResourceLogs, ShouldHaveLength, 2)
+			So(*requests[0].ResourceLogs[0].Resource.Attributes[0].Value.StringValue, ShouldEqual, "service-a")
+			So(requests[0].ResourceLogs[0].ScopeLogs[0].LogRecords, ShouldHaveLength, 2)
+			So(requests[0].ResourceLogs[0].ScopeLogs[0].Scope.Name, ShouldEqual, otlpScopeName)
+			So(*requests[0].ResourceLogs[1].ScopeLogs[0].LogRecords[0].Body.StringValue, ShouldEqual, "three")
+		})
+	})
+
+	Convey("The endpoint defaults to a local collector", t, func() {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", "")
+		So(otlpEndpoint(""), ShouldEqual, DefaultOTLPEndpoint)
+		So(otlpEndpoint("http://collector/v1/logs"), ShouldEqual, "http://collector/v1/logs")
+	})
+}
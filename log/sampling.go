@@ -0,0 +1,277 @@
+package log
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultSamplingInterval is the interval used for sampling if
+	// SamplingOptions.Interval isn't set
+	DefaultSamplingInterval = time.Second
+
+	// DefaultSamplingSummaryInterval is how often summaries of suppressed
+	// events are logged if SamplingOptions.SummaryInterval isn't set
+	DefaultSamplingSummaryInterval = 10 * time.Second
+)
+
+// SamplingOptions configures the sampling and rate limiting of events with
+// the same name, see SetSampling
+type SamplingOptions struct {
+	// First events with the same name are logged in each Interval, and then
+	// every Thereafter-th event. Events aren't sampled if First and
+	// Thereafter are both zero, and only the first events are logged if
+	// Thereafter is zero.
+	First      int
+	Thereafter int
+	Interval   time.Duration
+
+	// RatePerSecond limits the rate of events with the same name using a
+	// token bucket which holds Burst tokens. Events aren't rate limited if
+	// it's zero.
+	RatePerSecond float64
+	Burst         int
+
+	// PerSeverity samples events with the same name but a different
+	// severity separately
+	PerSeverity bool
+
+	// SampleErrors includes ERROR events, which aren't sampled by default.
+	// FATAL events are never sampled.
+	SampleErrors bool
+
+	// SummaryInterval is how often an event is logged for each event name
+	// with the number of events which were suppressed
+	SummaryInterval time.Duration
+}
+
+// samplingKey identifies events which are sampled together
+type samplingKey struct {
+	event    string
+	severity severity
+}
+
+// samplingCounter holds the sampling state for a samplingKey
+type samplingCounter struct {
+	windowStart time.Time
+	count       int
+
+	tokens     float64
+	lastRefill time.Time
+
+	suppressed         int64
+	suppressedSeverity severity
+	lastSeen           time.Time
+}
+
+// sampler decides whether events should be logged
+type sampler struct {
+	opts SamplingOptions
+	now  func() time.Time
+
+	mu       sync.Mutex
+	counters map[samplingKey]*samplingCounter
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// activeSampler is the sampler set by SetSampling, or nil
+var activeSampler atomic.Pointer[sampler]
+
+// SetSampling enables sampling and rate limiting of events with the same
+// name, so that an event logged in a tight loop doesn't drown out everything
+// else. For example, to log the first 10 events with each name every second,
+// and then every 100th:
+//
+//	log.SetSampling(&log.SamplingOptions{First: 10, Thereafter: 100})
+//
+// An event is logged periodically with the number of events which were
+// suppressed for each event name. Pass nil to disable sampling, which logs
+// any summaries which are waiting.
+func SetSampling(opts *SamplingOptions) {
+	var s *sampler
+	if opts != nil {
+		s = newSampler(*opts, time.Now)
+		s.start()
+	}
+
+	if old := activeSampler.Swap(s); old != nil {
+		old.stop()
+	}
+}
+
+func newSampler(opts SamplingOptions, now func() time.Time) *sampler {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultSamplingInterval
+	}
+	if opts.SummaryInterval <= 0 {
+		opts.SummaryInterval = DefaultSamplingSummaryInterval
+	}
+	if opts.RatePerSecond > 0 && opts.Burst <= 0 {
+		opts.Burst = int(math.Max(1, math.Ceil(opts.RatePerSecond)))
+	}
+
+	return &sampler{
+		opts:     opts,
+		now:      now,
+		counters: map[samplingKey]*samplingCounter{},
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *sampler) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.opts.SummaryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.summarise()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the summary goroutine, and logs any summaries which are waiting
+func (s *sampler) stop() {
+	close(s.done)
+	s.wg.Wait()
+	s.summarise()
+}
+
+// sampleEvent returns true if an event should be logged, using the active
+// sampler if there is one
+func sampleEvent(event string, sev severity) bool {
+	s := activeSampler.Load()
+	if s == nil {
+		return true
+	}
+	return s.allow(event, sev)
+}
+
+// allow returns true if an event should be logged, and counts it as
+// suppressed if not
+func (s *sampler) allow(event string, sev severity) bool {
+	if sev == FATAL || (sev == ERROR && !s.opts.SampleErrors) {
+		return true
+	}
+
+	key := samplingKey{event: event}
+	if s.opts.PerSeverity {
+		key.severity = sev
+	}
+
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok {
+		c = &samplingCounter{windowStart: now, tokens: float64(s.opts.Burst), lastRefill: now}
+		s.counters[key] = c
+	}
+	c.lastSeen = now
+
+	if s.sampled(c, now) && s.rateLimited(c, now) {
+		return true
+	}
+
+	if c.suppressed == 0 || sev < c.suppressedSeverity {
+		c.suppressedSeverity = sev
+	}
+	c.suppressed++
+	return false
+}
+
+// sampled returns true if an event is logged by sampling
+func (s *sampler) sampled(c *samplingCounter, now time.Time) bool {
+	if s.opts.First <= 0 && s.opts.Thereafter <= 0 {
+		return true
+	}
+
+	if now.Sub(c.windowStart) >= s.opts.Interval {
+		c.windowStart = now
+		c.count = 0
+	}
+	c.count++
+
+	if c.count <= s.opts.First {
+		return true
+	}
+	return s.opts.Thereafter > 0 && (c.count-s.opts.First)%s.opts.Thereafter == 0
+}
+
+// rateLimited returns true if an event is allowed by the token bucket
+func (s *sampler) rateLimited(c *samplingCounter, now time.Time) bool {
+	if s.opts.RatePerSecond <= 0 {
+		return true
+	}
+
+	c.tokens = math.Min(float64(s.opts.Burst), c.tokens+now.Sub(c.lastRefill).Seconds()*s.opts.RatePerSecond)
+	c.lastRefill = now
+
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+// samplingSummary is the number of events suppressed for an event name
+type samplingSummary struct {
+	event      string
+	severity   severity
+	suppressed int64
+}
+
+// takeSummaries returns the number of events suppressed since the previous
+// summary, and forgets event names which haven't been seen recently
+func (s *sampler) takeSummaries() []samplingSummary {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []samplingSummary
+	for key, c := range s.counters {
+		if c.suppressed > 0 {
+			summaries = append(summaries, samplingSummary{event: key.event, severity: c.suppressedSeverity, suppressed: c.suppressed})
+			c.suppressed = 0
+		} else if now.Sub(c.lastSeen) > s.opts.Interval+s.opts.SummaryInterval {
+			delete(s.counters, key)
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].event != summaries[j].event {
+			return summaries[i].event < summaries[j].event
+		}
+		return summaries[i].severity < summaries[j].severity
+	})
+	return summaries
+}
+
+// summarise logs an event for each event name with suppressed events. The
+// summaries aren't sampled, and use the most severe suppressed severity so
+// they aren't hidden by the minimum severity.
+func (s *sampler) summarise() {
+	ctx := context.Background()
+	for _, summary := range s.takeSummaries() {
+		writeEvent(ctx, createEvent(ctx, "events suppressed by sampling", summary.severity, Data{
+			"event":      summary.event,
+			"suppressed": summary.suppressed,
+		}))
+	}
+}
@@ -0,0 +1,180 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeClock is a time source for tests which only changes when advanced
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// allowed returns how many of n events are allowed by s
+func allowed(s *sampler, event string, sev severity, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if s.allow(event, sev) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSampler(t *testing.T) {
+	Convey("Given a sampler which logs the first 2 events per second and then every 3rd", t, func() {
+		clock := &fakeClock{t: time.Now()}
+		s := newSampler(SamplingOptions{First: 2, Thereafter: 3}, clock.now)
+
+		Convey("The first events and then every 3rd are allowed", func() {
+			var results []bool
+			for i := 0; i < 8; i++ {
+				results = append(results, s.allow("retrying", INFO))
+			}
+			So(results, ShouldResemble, []bool{true, true, false, false, true, false, false, true})
+
+			Convey("And the count is reset after the interval", func() {
+				clock.advance(time.Second)
+				So(s.allow("retrying", INFO), ShouldBeTrue)
+				So(s.allow("retrying", INFO), ShouldBeTrue)
+				So(s.allow("retrying", INFO), ShouldBeFalse)
+			})
+
+			Convey("And suppressed events are summarised", func() {
+				So(s.takeSummaries(), ShouldResemble, []samplingSummary{{event: "retrying", severity: INFO, suppressed: 4}})
+				So(s.takeSummaries(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("Events with different names are sampled separately", func() {
+			So(allowed(s, "one", INFO, 2), ShouldEqual, 2)
+			So(allowed(s, "two", INFO, 2), ShouldEqual, 2)
+		})
+
+		Convey("Events with different severities are sampled together", func() {
+			So(allowed(s, "retrying", INFO, 2), ShouldEqual, 2)
+			So(allowed(s, "retrying", WARN, 1), ShouldEqual, 0)
+			So(s.takeSummaries()[0].severity, ShouldEqual, WARN)
+		})
+
+		Convey("ERROR and FATAL events are not sampled", func() {
+			So(allowed(s, "failed", ERROR, 10), ShouldEqual, 10)
+			So(allowed(s, "failed", FATAL, 10), ShouldEqual, 10)
+		})
+	})
+
+	Convey("Given a sampler which samples each severity and errors", t, func() {
+		clock := &fakeClock{t: time.Now()}
+		s := newSampler(SamplingOptions{First: 1, PerSeverity: true, SampleErrors: true}, clock.now)
+
+		Convey("Events with different severities are sampled separately", func() {
+			So(allowed(s, "retrying", INFO, 3), ShouldEqual, 1)
+			So(allowed(s, "retrying", WARN, 3), ShouldEqual, 1)
+		})
+
+		Convey("ERROR events are sampled", func() {
+			So(allowed(s, "failed", ERROR, 3), ShouldEqual, 1)
+		})
+
+		Convey("FATAL events are not sampled", func() {
+			So(allowed(s, "failed", FATAL, 3), ShouldEqual, 3)
+		})
+	})
+
+	Convey("Given a sampler with a rate limit of 10 events per second", t, func() {
+		clock := &fakeClock{t: time.Now()}
+		s := newSampler(SamplingOptions{RatePerSecond: 10, Burst: 5}, clock.now)
+
+		Convey("A burst of events is allowed", func() {
+			So(allowed(s, "retrying", INFO, 20), ShouldEqual, 5)
+
+			Convey("And then 10 events per second", func() {
+				clock.advance(500 * time.Millisecond)
+				So(allowed(s, "retrying", INFO, 20), ShouldEqual, 5)
+				clock.advance(100 * time.Millisecond)
+				So(allowed(s, "retrying", INFO, 20), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Event names which haven't been seen recently are forgotten", t, func() {
+		clock := &fakeClock{t: time.Now()}
+		s := newSampler(SamplingOptions{First: 1}, clock.now)
+
+		s.allow("retrying", INFO)
+		clock.advance(time.Minute)
+		s.takeSummaries()
+		So(s.counters, ShouldBeEmpty)
+	})
+}
+
+func TestSetSampling(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+		SetSampling(nil)
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+
+	Convey("Given sampling is enabled", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+		SetSampling(&SamplingOptions{First: 2, Interval: time.Hour, SummaryInterval: time.Hour})
+
+		for i := 0; i < 5; i++ {
+			Warn(context.Background(), "retrying connection")
+		}
+		Error(context.Background(), "connection failed", nil)
+
+		Convey("Suppressed events are not logged", func() {
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"retrying connection", "retrying connection", "connection failed"})
+
+			Convey("And are summarised when sampling is disabled", func() {
+				SetSampling(nil)
+
+				events := loggedEvents(buf)
+				So(events, ShouldHaveLength, 4)
+				So(events[3]["event"], ShouldEqual, "events suppressed by sampling")
+				So(events[3]["severity"], ShouldEqual, WARN)
+				So(events[3]["data"], ShouldResemble, map[string]interface{}{"event": "retrying connection", "suppressed": float64(3)})
+			})
+		})
+
+		SetSampling(nil)
+	})
+
+	Convey("Given ERROR events are sampled", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+		SetSampling(&SamplingOptions{First: 1, Interval: time.Hour, SampleErrors: true})
+		defer SetSampling(nil)
+
+		ctx := ContextWithDebugBuffer(context.Background(), 10)
+		Error(ctx, "connection failed", nil)
+		buf.Reset()
+
+		Convey("A suppressed ERROR event still flushes the debug buffer", func() {
+			Debug(ctx, "connecting")
+			Error(ctx, "connection failed", nil)
+
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"connecting"})
+		})
+	})
+}
@@ -1,5 +1,11 @@
 package log
 
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+)
+
 const (
 	// FATAL is an option you can pass to Event to specify a severity of FATAL/0
 	FATAL severity = 0
@@ -9,6 +15,10 @@ const (
 	WARN severity = 2
 	// INFO is an option you can pass to Event to specify a severity of INFO/3
 	INFO severity = 3
+	// DEBUG is an option you can pass to Event to specify a severity of DEBUG/4
+	DEBUG severity = 4
+	// TRACE is an option you can pass to Event to specify a severity of TRACE/5
+	TRACE severity = 5
 )
 
 // severity is the log severity level
@@ -20,3 +30,65 @@ type severity int
 func (s severity) attach(le *EventData) {
 	le.Severity = &s
 }
+
+// minimumSeverity is the least severe level which is logged, and
+// is stored as an int32 so it can be changed while logging
+var minimumSeverity = func() *atomic.Int32 {
+	var v atomic.Int32
+	v.Store(int32(INFO))
+	return &v
+}()
+
+// SetMinimumSeverity sets the least severe level which is logged. Events
+// which are less severe (e.g. DEBUG events when set to INFO) are discarded,
+// unless they are captured by a debug buffer (see ContextWithDebugBuffer).
+//
+// It defaults to INFO.
+func SetMinimumSeverity(s severity) {
+	minimumSeverity.Store(int32(s))
+}
+
+// ContextWithMinimumSeverity returns a copy of ctx which lowers the minimum
+// severity for events logged with it, for example to log DEBUG events for a
+// single request. It can't be used to raise the minimum severity above the
+// level set by SetMinimumSeverity.
+//
+// See also WithSeverityHeader, which does this for requests handled by
+// Middleware.
+func ContextWithMinimumSeverity(ctx context.Context, s severity) context.Context {
+	return context.WithValue(ctx, minimumSeverityContextKey, s)
+}
+
+// severityEnabled returns true if events of severity s should be logged with ctx
+func severityEnabled(ctx context.Context, s severity) bool {
+	if int32(s) <= minimumSeverity.Load() {
+		return true
+	}
+
+	if ctx != nil {
+		if override, ok := ctx.Value(minimumSeverityContextKey).(severity); ok {
+			return s <= override
+		}
+	}
+
+	return false
+}
+
+// parseSeverity returns the severity named by s, e.g. "debug" or "WARN"
+func parseSeverity(s string) (severity, bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "FATAL":
+		return FATAL, true
+	case "ERROR":
+		return ERROR, true
+	case "WARN", "WARNING":
+		return WARN, true
+	case "INFO":
+		return INFO, true
+	case "DEBUG":
+		return DEBUG, true
+	case "TRACE":
+		return TRACE, true
+	}
+	return 0, false
+}
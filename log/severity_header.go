@@ -0,0 +1,66 @@
+package log
+
+import (
+	"net/http"
+)
+
+// SeverityHeader is the request header used to lower the minimum severity
+// for a single request, see WithSeverityHeader
+const SeverityHeader = "X-Log-Level"
+
+// SeverityOverrideAuthoriser returns true if the request is allowed to
+// change the minimum severity using the SeverityHeader
+type SeverityOverrideAuthoriser func(req *http.Request) bool
+
+// WithSeverityHeader returns a MiddlewareOption which lets a request lower the
+// minimum severity for events logged with its context, by sending a severity
+// name (e.g. "debug") in the X-Log-Level header.
+//
+// The header is only honoured if authorise returns true for the request, so
+// it can't be used by the public to increase log volume. If authorise is nil
+// the header is always ignored. When the header is honoured an event is
+// logged, so that its use can be audited.
+func WithSeverityHeader(authorise SeverityOverrideAuthoriser) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.severityAuthoriser = authorise
+	}
+}
+
+// AllowSeverityOverrideFrom is a SeverityOverrideAuthoriser which allows the
+// listed identities to use the SeverityHeader. It uses the identity stored
+// in the request context, e.g. by WithIdentityExtractor.
+func AllowSeverityOverrideFrom(identities ...string) SeverityOverrideAuthoriser {
+	allowed := make(map[string]struct{}, len(identities))
+	for _, id := range identities {
+		allowed[id] = struct{}{}
+	}
+
+	return func(req *http.Request) bool {
+		auth := authFromContext(req.Context())
+		if auth == nil || auth.Identity == "" {
+			return false
+		}
+		_, ok := allowed[auth.Identity]
+		return ok
+	}
+}
+
+// withSeverityFromHeader returns req with the minimum severity from the
+// SeverityHeader stored in its context, if the header is present, valid
+// and authorised
+func withSeverityFromHeader(req *http.Request, authorise SeverityOverrideAuthoriser) *http.Request {
+	value := req.Header.Get(SeverityHeader)
+	if value == "" || authorise == nil {
+		return req
+	}
+
+	s, ok := parseSeverity(value)
+	if !ok || !authorise(req) {
+		return req
+	}
+
+	ctx := ContextWithMinimumSeverity(req.Context(), s)
+	Event(ctx, "minimum severity overridden for request", INFO, Data{"severity": s})
+
+	return req.WithContext(ctx)
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAllowSeverityOverrideFrom(t *testing.T) {
+	Convey("AllowSeverityOverrideFrom allows listed identities", t, func() {
+		authorise := AllowSeverityOverrideFrom("support@ons.gov.uk")
+		req := httptest.NewRequest("GET", "/", http.NoBody)
+		So(authorise(req), ShouldBeFalse)
+
+		allowed := req.WithContext(WithAuth(req.Context(), &EventAuth{Identity: "support@ons.gov.uk", IdentityType: USER}))
+		So(authorise(allowed), ShouldBeTrue)
+
+		other := req.WithContext(WithAuth(req.Context(), &EventAuth{Identity: "public@example.com", IdentityType: USER}))
+		So(authorise(other), ShouldBeFalse)
+	})
+}
+
+func TestMiddlewareSeverityHeader(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+
+	Convey("Given a middleware which honours the severity header", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+
+		authorised := true
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			Debug(req.Context(), "handler debug")
+		})
+		m := Middleware(h, WithSeverityHeader(func(*http.Request) bool { return authorised }))
+
+		newRequest := func(level string) *http.Request {
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.Header.Set(SeverityHeader, level)
+			return req
+		}
+
+		Convey("When an authorised request sets the header, DEBUG events are logged for that request", func() {
+			m.ServeHTTP(httptest.NewRecorder(), newRequest("debug"))
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{
+				"minimum severity overridden for request", "http request received", "handler debug", "http request completed",
+			})
+
+			Convey("And not for other requests", func() {
+				buf.Reset()
+				m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+				So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"http request received", "http request completed"})
+			})
+		})
+
+		Convey("When an unauthorised request sets the header, it is ignored", func() {
+			authorised = false
+			m.ServeHTTP(httptest.NewRecorder(), newRequest("debug"))
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"http request received", "http request completed"})
+		})
+
+		Convey("When the header isn't a valid severity, it is ignored", func() {
+			m.ServeHTTP(httptest.NewRecorder(), newRequest("loud"))
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"http request received", "http request completed"})
+		})
+	})
+
+	Convey("Given a middleware with a nil authoriser, the header is ignored", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			Debug(req.Context(), "handler debug")
+		})
+		req := httptest.NewRequest("GET", "/", http.NoBody)
+		req.Header.Set(SeverityHeader, "debug")
+		Middleware(h, WithSeverityHeader(nil)).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"http request received", "http request completed"})
+	})
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -21,6 +22,8 @@ func TestSeverity(t *testing.T) {
 		So(ERROR, ShouldHaveSameTypeAs, severity(-1))
 		So(WARN, ShouldHaveSameTypeAs, severity(-1))
 		So(INFO, ShouldHaveSameTypeAs, severity(-1))
+		So(DEBUG, ShouldHaveSameTypeAs, severity(-1))
+		So(TRACE, ShouldHaveSameTypeAs, severity(-1))
 	})
 
 	Convey("severity values match logging spec", t, func() {
@@ -28,5 +31,46 @@ func TestSeverity(t *testing.T) {
 		So(ERROR, ShouldEqual, 1)
 		So(WARN, ShouldEqual, 2)
 		So(INFO, ShouldEqual, 3)
+		So(DEBUG, ShouldEqual, 4)
+		So(TRACE, ShouldEqual, 5)
+	})
+
+	Convey("the minimum severity controls which events are logged", t, func() {
+		defer SetMinimumSeverity(INFO)
+
+		So(severityEnabled(nil, FATAL), ShouldBeTrue)
+		So(severityEnabled(nil, INFO), ShouldBeTrue)
+		So(severityEnabled(nil, DEBUG), ShouldBeFalse)
+
+		SetMinimumSeverity(DEBUG)
+		So(severityEnabled(nil, DEBUG), ShouldBeTrue)
+
+		SetMinimumSeverity(ERROR)
+		So(severityEnabled(nil, ERROR), ShouldBeTrue)
+		So(severityEnabled(nil, WARN), ShouldBeFalse)
+	})
+
+	Convey("a context can lower the minimum severity", t, func() {
+		ctx := ContextWithMinimumSeverity(context.Background(), DEBUG)
+		So(severityEnabled(ctx, DEBUG), ShouldBeTrue)
+		So(severityEnabled(context.Background(), DEBUG), ShouldBeFalse)
+
+		Convey("but can't raise it", func() {
+			ctx := ContextWithMinimumSeverity(context.Background(), ERROR)
+			So(severityEnabled(ctx, INFO), ShouldBeTrue)
+		})
+	})
+
+	Convey("parseSeverity parses severity names", t, func() {
+		for name, expected := range map[string]severity{
+			"fatal": FATAL, "ERROR": ERROR, "warn": WARN, "Warning": WARN, " info ": INFO, "debug": DEBUG, "trace": TRACE,
+		} {
+			s, ok := parseSeverity(name)
+			So(ok, ShouldBeTrue)
+			So(s, ShouldEqual, expected)
+		}
+
+		_, ok := parseSeverity("verbose")
+		So(ok, ShouldBeFalse)
 	})
 }
@@ -0,0 +1,210 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultSinkQueueSize is the number of events which can be queued for
+// a sink before events are dropped, if SinkQueueSize isn't used
+const DefaultSinkQueueSize = 1024
+
+// Sink is an additional destination for log output, registered with AddSink.
+//
+// Each sink has its own minimum severity, encoder and filter, and writes
+// events from its own goroutine, so that a slow or failing sink doesn't
+// block logging or other sinks. Events are dropped if a sink's queue is full.
+type Sink struct {
+	w               io.Writer
+	minimumSeverity severity
+	encoder         Encoder
+	filter          func(e *EventData) bool
+	queueSize       int
+	// closeWriter is true for writers created by the package, e.g. by
+	// AddSyslogSink, which are closed when the sink is closed
+	closeWriter bool
+
+	queue     chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// SinkOption is an option you can pass to AddSink
+type SinkOption func(*Sink)
+
+// SinkMinimumSeverity sets the least severe level written to the sink. It
+// can't be used to log events which are less severe than the minimum severity
+// set by SetMinimumSeverity. All events are written to the sink by default.
+func SinkMinimumSeverity(s severity) SinkOption {
+	return func(sink *Sink) {
+		sink.minimumSeverity = s
+	}
+}
+
+// SinkEncoder sets the encoder used for the sink, which is JSONEncoder
+// by default
+func SinkEncoder(enc Encoder) SinkOption {
+	return func(sink *Sink) {
+		sink.encoder = enc
+	}
+}
+
+// SinkFilter sets a function which returns true for the events which should
+// be written to the sink, for example to write audit events to a separate
+// file. The event mustn't be modified.
+func SinkFilter(f func(e *EventData) bool) SinkOption {
+	return func(sink *Sink) {
+		sink.filter = f
+	}
+}
+
+// SinkQueueSize sets the number of events which can be queued for the sink
+func SinkQueueSize(n int) SinkOption {
+	return func(sink *Sink) {
+		if n > 0 {
+			sink.queueSize = n
+		}
+	}
+}
+
+// sinkClosesWriter makes Close close the writer, for sinks which write to a
+// writer created by the package
+func sinkClosesWriter() SinkOption {
+	return func(sink *Sink) {
+		sink.closeWriter = true
+	}
+}
+
+// sinks is the list of registered sinks, which is replaced rather than
+// modified so it can be read without holding sinksMutex
+var sinks atomic.Pointer[[]*Sink]
+var sinksMutex sync.Mutex
+
+// AddSink registers an additional destination for log output, for example
+// to write ERROR events to a local file in console format:
+//
+//	sink := log.AddSink(file, log.SinkMinimumSeverity(log.ERROR), log.SinkEncoder(log.ConsoleEncoder))
+//	defer sink.Close()
+//
+// Events are still written to the destination set by SetDestination, which
+// can be set to io.Discard if only sinks should be used. The writer isn't
+// closed when the sink is closed.
+func AddSink(w io.Writer, opts ...SinkOption) *Sink {
+	s := &Sink{
+		w:               w,
+		minimumSeverity: TRACE,
+		encoder:         JSONEncoder,
+		queueSize:       DefaultSinkQueueSize,
+		done:            make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	s.queue = make(chan []byte, s.queueSize)
+
+	go s.run()
+
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	var updated []*Sink
+	if current := sinks.Load(); current != nil {
+		updated = append(updated, *current...)
+	}
+	updated = append(updated, s)
+	sinks.Store(&updated)
+
+	return s
+}
+
+// Close removes the sink, and waits for any queued events to be written.
+// Sinks added by AddSyslogSink, AddJournaldSink and AddOTLPSink also close
+// the connection they write to, but the writer passed to AddSink is left open.
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		sinksMutex.Lock()
+		if current := sinks.Load(); current != nil {
+			updated := make([]*Sink, 0, len(*current))
+			for _, other := range *current {
+				if other != s {
+					updated = append(updated, other)
+				}
+			}
+			sinks.Store(&updated)
+		}
+		// holding sinksMutex here stops a concurrent dispatch from sending
+		// to the queue after it's closed
+		close(s.queue)
+		sinksMutex.Unlock()
+	})
+
+	<-s.done
+
+	if c, ok := s.w.(io.Closer); ok && s.closeWriter {
+		return c.Close()
+	}
+	return nil
+}
+
+// Dropped returns the number of events which were dropped because the
+// sink's queue was full
+func (s *Sink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Failed returns the number of events which couldn't be written to the sink
+func (s *Sink) Failed() int64 {
+	return s.failed.Load()
+}
+
+// run writes queued events until the queue is closed
+func (s *Sink) run() {
+	defer close(s.done)
+	for b := range s.queue {
+		if _, err := writeAll(s.w, b); err != nil {
+			s.failed.Add(1)
+		}
+	}
+}
+
+// accepts returns true if e should be written to the sink
+func (s *Sink) accepts(e *EventData) bool {
+	if e.Severity != nil && *e.Severity > s.minimumSeverity {
+		return false
+	}
+	return s.filter == nil || s.filter(e)
+}
+
+// dispatchToSinks encodes e for each sink which accepts it, and queues it to
+// be written. It is called from the goroutine which logged the event, so the
+// event data isn't used after the caller has returned.
+func dispatchToSinks(e *EventData) {
+	current := sinks.Load()
+	if current == nil || len(*current) == 0 {
+		return
+	}
+
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	for _, s := range *sinks.Load() {
+		if !s.accepts(e) {
+			continue
+		}
+
+		b, err := s.encoder(*e)
+		if err != nil {
+			s.failed.Add(1)
+			continue
+		}
+
+		select {
+		case s.queue <- append(b, '\n'):
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
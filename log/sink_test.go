@@ -0,0 +1,150 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// syncBuffer is a bytes.Buffer which can be written by a sink goroutine
+// while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSink(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+
+	Convey("Given a destination and two sinks", t, func() {
+		buf := &bytes.Buffer{}
+		destination = buf
+
+		all := &syncBuffer{}
+		errorsOnly := &syncBuffer{}
+		allSink := AddSink(all)
+		errorSink := AddSink(errorsOnly, SinkMinimumSeverity(ERROR), SinkEncoder(ConsoleEncoder))
+
+		Info(context.Background(), "info event")
+		Error(context.Background(), "error event", errors.New("test error"))
+
+		So(allSink.Close(), ShouldBeNil)
+		So(errorSink.Close(), ShouldBeNil)
+
+		Convey("Events are written to the destination", func() {
+			So(eventNames(loggedEvents(buf)), ShouldResemble, []string{"info event", "error event"})
+		})
+
+		Convey("Events are written to each sink which accepts them", func() {
+			So(eventNames(loggedEvents(bytes.NewBufferString(all.String()))), ShouldResemble, []string{"info event", "error event"})
+
+			lines := strings.Split(strings.TrimSpace(errorsOnly.String()), "\n")
+			So(lines, ShouldHaveLength, 1)
+			So(lines[0], ShouldContainSubstring, " ERROR error event ")
+			So(lines[0], ShouldContainSubstring, `error="test error"`)
+		})
+
+		Convey("Events are not written to a sink after it is closed", func() {
+			Info(context.Background(), "after close")
+			So(all.String(), ShouldNotContainSubstring, "after close")
+		})
+	})
+
+	Convey("Given a sink with a filter", t, func() {
+		destination = io.Discard
+
+		audit := &syncBuffer{}
+		sink := AddSink(audit, SinkFilter(func(e *EventData) bool {
+			return strings.HasPrefix(e.Event, "audit:")
+		}))
+
+		Info(context.Background(), "audit: user signed in")
+		Info(context.Background(), "something else")
+		So(sink.Close(), ShouldBeNil)
+
+		Convey("Only matching events are written to the sink", func() {
+			So(eventNames(loggedEvents(bytes.NewBufferString(audit.String()))), ShouldResemble, []string{"audit: user signed in"})
+		})
+	})
+
+	Convey("Given a sink which blocks and a sink which fails", t, func() {
+		destination = io.Discard
+
+		unblock := make(chan struct{})
+		blocked := AddSink(writer{func(b []byte) (int, error) {
+			<-unblock
+			return len(b), nil
+		}}, SinkQueueSize(1))
+		failing := AddSink(writer{func(b []byte) (int, error) {
+			return 0, errors.New("write failed")
+		}})
+		healthy := &syncBuffer{}
+		healthySink := AddSink(healthy)
+
+		for i := 0; i < 5; i++ {
+			Info(context.Background(), "event")
+		}
+
+		So(healthySink.Close(), ShouldBeNil)
+		So(failing.Close(), ShouldBeNil)
+
+		Convey("Other sinks are not affected", func() {
+			So(loggedEvents(bytes.NewBufferString(healthy.String())), ShouldHaveLength, 5)
+		})
+
+		Convey("Failed writes are counted", func() {
+			So(failing.Failed(), ShouldEqual, 5)
+		})
+
+		Convey("Events are dropped when the blocked sink's queue is full", func() {
+			So(blocked.Dropped(), ShouldBeGreaterThanOrEqualTo, 3)
+		})
+
+		close(unblock)
+		So(blocked.Close(), ShouldBeNil)
+	})
+
+	Convey("Given a sink which writes to a file", t, func() {
+		destination = io.Discard
+
+		f, err := os.Create(filepath.Join(t.TempDir(), "sink.log"))
+		So(err, ShouldBeNil)
+		defer f.Close()
+		sink := AddSink(f)
+
+		Convey("Closing the sink doesn't close the file", func() {
+			So(sink.Close(), ShouldBeNil)
+			_, err := f.Write([]byte("after close\n"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
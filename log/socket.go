@@ -0,0 +1,91 @@
+package log
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// socketWriter is an io.Writer which writes each event as a message to a
+// socket, redialling once if a write fails, e.g. because the daemon listening
+// on the socket has restarted
+type socketWriter struct {
+	network string
+	address string
+
+	// trimNewline removes the newline added to each event by a sink
+	trimNewline bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// dialSocket returns a socketWriter connected to address
+func dialSocket(network, address string, trimNewline bool) (*socketWriter, error) {
+	w := &socketWriter{network: network, address: address, trimNewline: trimNewline}
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *socketWriter) dial() error {
+	conn, err := net.Dial(w.network, w.address)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// isStream returns true if the socket is connection oriented, in which case
+// messages are framed by prefixing their length as described in RFC 6587
+func (w *socketWriter) isStream() bool {
+	switch w.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	}
+	return false
+}
+
+// Write writes b as a single message
+func (w *socketWriter) Write(b []byte) (int, error) {
+	msg := b
+	if w.trimNewline {
+		msg = bytes.TrimSuffix(msg, []byte("\n"))
+	}
+	if w.isStream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err = w.dial(); err != nil {
+				continue
+			}
+		}
+		if _, err = writeAll(w.conn, msg); err == nil {
+			return len(b), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return 0, err
+}
+
+// Close closes the socket
+func (w *socketWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
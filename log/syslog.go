@@ -0,0 +1,152 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSyslogAddress is the local syslog socket
+	DefaultSyslogAddress = "/dev/log"
+
+	// DefaultSyslogStructuredDataID is the SD-ID of the structured data
+	// element containing the trace ID and namespace. 32473 is the private
+	// enterprise number reserved for documentation by RFC 5612.
+	DefaultSyslogStructuredDataID = "log@32473"
+
+	// SyslogFacilityUser is the "user-level messages" facility, which is
+	// used if SyslogOptions.Facility isn't set
+	SyslogFacilityUser = 1
+	// SyslogFacilityLocal0 is the first of the local use facilities,
+	// local0 to local7 are 16 to 23
+	SyslogFacilityLocal0 = 16
+)
+
+// SyslogOptions configures a syslog destination
+type SyslogOptions struct {
+	// Network and Address of the syslog daemon, which default to the local
+	// syslog socket. Stream sockets ("tcp" or "unix") use octet counting framing.
+	Network string
+	Address string
+
+	// Facility is the syslog facility code, e.g. SyslogFacilityLocal0
+	Facility int
+
+	// Hostname defaults to the hostname reported by the kernel
+	Hostname string
+
+	// StructuredDataID defaults to DefaultSyslogStructuredDataID
+	StructuredDataID string
+}
+
+func (o SyslogOptions) withDefaults() SyslogOptions {
+	if o.Address == "" {
+		o.Address = DefaultSyslogAddress
+	}
+	if o.Network == "" {
+		o.Network = "unixgram"
+	}
+	if o.Facility == 0 {
+		o.Facility = SyslogFacilityUser
+	}
+	if o.Hostname == "" {
+		o.Hostname, _ = os.Hostname()
+	}
+	if o.StructuredDataID == "" {
+		o.StructuredDataID = DefaultSyslogStructuredDataID
+	}
+	return o
+}
+
+// AddSyslogSink registers a sink which writes events to syslog, see SyslogEncoder
+func AddSyslogSink(opts SyslogOptions, sinkOpts ...SinkOption) (*Sink, error) {
+	opts = opts.withDefaults()
+
+	w, err := dialSocket(opts.Network, opts.Address, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return AddSink(w, append([]SinkOption{SinkEncoder(SyslogEncoder(opts)), sinkClosesWriter()}, sinkOpts...)...), nil
+}
+
+// SyslogEncoder returns an encoder which encodes events as RFC 5424 syslog
+// messages. The trace ID, span ID and namespace are included as structured
+// data, and the message is the event in JSON format, e.g.
+//
+//	<11>1 2020-12-10T11:16:39.156Z host app 1234 - [log@32473 trace_id="abc123" namespace="app"] {"created_at":...}
+func SyslogEncoder(opts SyslogOptions) Encoder {
+	opts = opts.withDefaults()
+	hostname := syslogHeaderField(opts.Hostname, 255)
+	procID := strconv.Itoa(os.Getpid())
+
+	return func(e EventData) ([]byte, error) {
+		msg, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+
+		var sb strings.Builder
+		sb.WriteString("<" + strconv.Itoa(opts.Facility*8+syslogSeverity(e.Severity)) + ">1 ")
+		sb.WriteString(e.CreatedAt.UTC().Format(time.RFC3339Nano) + " ")
+		sb.WriteString(hostname + " ")
+		sb.WriteString(syslogHeaderField(e.Namespace, 48) + " ")
+		sb.WriteString(procID + " - ")
+
+		sb.WriteString("[" + opts.StructuredDataID)
+		if e.TraceID != "" {
+			sb.WriteString(` trace_id="` + syslogParamValue(e.TraceID) + `"`)
+		}
+		if e.SpanID != "" {
+			sb.WriteString(` span_id="` + syslogParamValue(e.SpanID) + `"`)
+		}
+		sb.WriteString(` namespace="` + syslogParamValue(e.Namespace) + `"] `)
+
+		sb.Write(msg)
+		return []byte(sb.String()), nil
+	}
+}
+
+// syslogSeverity maps a severity to a syslog severity code
+func syslogSeverity(s *severity) int {
+	if s == nil {
+		return 6
+	}
+	switch *s {
+	case FATAL:
+		return 2 // critical
+	case ERROR:
+		return 3 // error
+	case WARN:
+		return 4 // warning
+	case INFO:
+		return 6 // informational
+	}
+	return 7 // debug
+}
+
+// syslogHeaderField returns s restricted to printable US-ASCII and at most
+// max characters, or the nil value "-" if it's empty
+func syslogHeaderField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// syslogParamValue escapes a structured data parameter value
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
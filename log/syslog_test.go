@@ -0,0 +1,124 @@
+//go:build !windows
+
+package log
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// listenUnixgram returns a datagram socket in a temporary directory
+func listenUnixgram(t *testing.T) *net.UnixConn {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readDatagram reads the next message from conn
+func readDatagram(conn *net.UnixConn) (string, error) {
+	b := make([]byte, 65536)
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return "", err
+	}
+	n, err := conn.Read(b)
+	return string(b[:n]), err
+}
+
+func TestSyslogEncoder(t *testing.T) {
+	Convey("SyslogEncoder encodes an event as an RFC 5424 message", t, func() {
+		sev := ERROR
+		e := EventData{
+			CreatedAt: time.Date(2020, 12, 10, 11, 16, 39, 156000000, time.UTC),
+			Namespace: "my app",
+			Event:     "test",
+			TraceID:   `abc"]123`,
+			Severity:  &sev,
+		}
+
+		b, err := SyslogEncoder(SyslogOptions{Facility: SyslogFacilityLocal0, Hostname: "host"})(e)
+		So(err, ShouldBeNil)
+
+		pid := strconv.Itoa(os.Getpid())
+		So(string(b), ShouldStartWith, `<131>1 2020-12-10T11:16:39.156Z host my_app `+pid+` - [log@32473 trace_id="abc\"\]123" namespace="my app"] {"created_at"`)
+	})
+
+	Convey("Severities are mapped to syslog severities", t, func() {
+		for s, code := range map[severity]int{FATAL: 2, ERROR: 3, WARN: 4, INFO: 6, DEBUG: 7, TRACE: 7} {
+			s := s
+			So(syslogSeverity(&s), ShouldEqual, code)
+		}
+		So(syslogSeverity(nil), ShouldEqual, 6)
+	})
+}
+
+func TestSyslogSink(t *testing.T) {
+	oldDestination := destination
+	oldStyler := styler
+	oldEvent := eventFuncInst
+	defer func() {
+		destination = oldDestination
+		styler = oldStyler
+		eventFuncInst = oldEvent
+	}()
+	styler = styleForMachineFunc
+	eventFuncInst = eventWithOptionsCheckFunc
+	destination = &syncBuffer{}
+
+	Convey("Given a syslog sink", t, func() {
+		conn := listenUnixgram(t)
+
+		sink, err := AddSyslogSink(SyslogOptions{Address: conn.LocalAddr().String(), Hostname: "host"})
+		So(err, ShouldBeNil)
+		defer sink.Close()
+
+		Convey("Events are written to the socket as single messages", func() {
+			Error(context.Background(), "syslog test", errors.New("test error"))
+
+			msg, err := readDatagram(conn)
+			So(err, ShouldBeNil)
+			So(msg, ShouldStartWith, "<11>1 ")
+			So(msg, ShouldContainSubstring, `"event":"syslog test"`)
+			So(msg, ShouldNotEndWith, "\n")
+		})
+	})
+
+	Convey("AddSyslogSink returns an error if the socket doesn't exist", t, func() {
+		_, err := AddSyslogSink(SyslogOptions{Address: filepath.Join(t.TempDir(), "missing.sock")})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Messages written to a stream socket use octet counting", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer l.Close()
+
+		w, err := dialSocket("tcp", l.Addr().String(), true)
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		conn, err := l.Accept()
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		n, err := w.Write([]byte("hello\n"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 6)
+
+		b := make([]byte, 7)
+		_, err = conn.Read(b)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "5 hello")
+	})
+}
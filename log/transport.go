@@ -0,0 +1,63 @@
+package log
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Transport wraps a http.RoundTripper to log outbound HTTP requests
+//
+// It is the client side equivalent of Middleware, and can be used as the
+// Transport of a http.Client. If rt is nil, http.DefaultTransport is used.
+//
+// Each request will produce two log entries - one when the request is sent,
+// and another when the response is received. If the request fails, the
+// second entry is an error event including the transport error instead.
+//
+// The OpenTelemetry trace context and request ID from the request context
+// are added to the outbound request headers, so the downstream service can
+// log events with the same trace ID.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &loggingTransport{rt}
+}
+
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// a RoundTripper mustn't modify the request, so headers are set on a clone
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if requestID := getRequestID(ctx); requestID != "" && req.Header.Get(request.RequestHeaderKey) == "" {
+		request.AddRequestIdHeader(req, requestID)
+	}
+
+	start := time.Now().UTC()
+	Event(ctx, "http request sent", INFO, HTTP(req, 0, 0, &start, nil))
+
+	resp, err := t.base.RoundTrip(req)
+	end := time.Now().UTC()
+
+	if err != nil {
+		Event(ctx, "http request failed", ERROR, HTTP(req, 0, 0, &start, &end), FormatErrors([]error{err}))
+		return resp, err
+	}
+
+	var contentLength int64
+	if resp.ContentLength > 0 {
+		contentLength = resp.ContentLength
+	}
+	Event(ctx, "http response received", INFO, HTTP(req, resp.StatusCode, contentLength, &start, &end))
+
+	return resp, nil
+}
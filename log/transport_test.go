@@ -0,0 +1,114 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransport(t *testing.T) {
+	mock := &eventFuncMock{}
+	oldEvent := eventFuncInst
+	defer func() {
+		eventFuncInst = oldEvent
+	}()
+	eventFuncInst = &eventFunc{mock.Event}
+
+	Convey("Given a http.Client using Transport", t, func() {
+		events := make([]eventFuncMock, 0)
+		mock.onEvent = func(e eventFuncMock) {
+			events = append(events, e)
+		}
+
+		var inboundRequestID string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			inboundRequestID = req.Header.Get(request.RequestHeaderKey)
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("response"))
+		}))
+		defer srv.Close()
+
+		client := &http.Client{Transport: Transport(nil)}
+
+		Convey("When a request is made", func() {
+			ctx := request.WithRequestId(context.Background(), "request-id")
+			req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/a/b", http.NoBody)
+			So(err, ShouldBeNil)
+
+			resp, err := client.Do(req)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+
+			Convey("Then sent and received events are logged", func() {
+				So(events, ShouldHaveLength, 2)
+
+				So(events[0].capEvent, ShouldEqual, "http request sent")
+				So(events[0].severity, ShouldEqual, INFO)
+				So(events[0].capCtx, ShouldEqual, ctx)
+				sent := events[0].capOpts[0].(*EventHTTP)
+				So(sent.Method, ShouldEqual, "GET")
+				So(sent.Host, ShouldEqual, "127.0.0.1")
+				So(sent.Path, ShouldEqual, "/a/b")
+				So(sent.Duration, ShouldBeNil)
+
+				So(events[1].capEvent, ShouldEqual, "http response received")
+				So(events[1].severity, ShouldEqual, INFO)
+				received := events[1].capOpts[0].(*EventHTTP)
+				So(*received.StatusCode, ShouldEqual, http.StatusTeapot)
+				So(received.Host, ShouldEqual, "127.0.0.1")
+				So(received.Duration, ShouldNotBeNil)
+				So(received.ResponseContentLength, ShouldEqual, 8)
+			})
+
+			Convey("Then the request ID is sent to the downstream service", func() {
+				So(inboundRequestID, ShouldEqual, "request-id")
+			})
+
+			Convey("Then the original request isn't modified", func() {
+				So(req.Header.Get(request.RequestHeaderKey), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a Transport wrapping a failing http.RoundTripper", t, func() {
+		events := make([]eventFuncMock, 0)
+		mock.onEvent = func(e eventFuncMock) {
+			events = append(events, e)
+		}
+
+		rtErr := errors.New("connection refused")
+		rt := Transport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, rtErr
+		}))
+
+		Convey("When a request is made", func() {
+			req, _ := http.NewRequest("GET", "http://downstream:8080/", http.NoBody)
+			resp, err := rt.RoundTrip(req)
+
+			Convey("Then the error is returned and logged", func() {
+				So(resp, ShouldBeNil)
+				So(err, ShouldEqual, rtErr)
+				So(events, ShouldHaveLength, 2)
+				So(events[1].capEvent, ShouldEqual, "http request failed")
+				So(events[1].severity, ShouldEqual, ERROR)
+				So(events[1].capOpts, ShouldHaveLength, 2)
+				failed := events[1].capOpts[0].(*EventHTTP)
+				So(failed.Host, ShouldEqual, "downstream")
+				So(failed.Port, ShouldEqual, 8080)
+				errs := events[1].capOpts[1].(*EventErrors)
+				So((*errs)[0].Message, ShouldEqual, "connection refused")
+			})
+		})
+	})
+}
@@ -0,0 +1,314 @@
+package log
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// WriteFailurePolicy determines what happens when an event can't be written
+// to either the destination or the fallback destination
+type WriteFailurePolicy int
+
+const (
+	// ExitOnWriteFailure panics and exits the process, which is the default
+	ExitOnWriteFailure WriteFailurePolicy = iota
+	// RetryOnWriteFailure retries the write in the background with
+	// exponential backoff, and drops the event if it still can't be written.
+	// Later events are queued behind it, so that events are written in order.
+	RetryOnWriteFailure
+	// DropOnWriteFailure drops the event
+	DropOnWriteFailure
+	// BufferOnWriteFailure keeps the most recent events in memory, and writes
+	// them before the next event once a write succeeds
+	BufferOnWriteFailure
+)
+
+const (
+	// DefaultWriteRetries is the number of times a write is retried with
+	// RetryOnWriteFailure, if WriteFailureOptions.Retries isn't set
+	DefaultWriteRetries = 3
+	// DefaultWriteBackoff is the delay before the first retry with
+	// RetryOnWriteFailure, if WriteFailureOptions.Backoff isn't set
+	DefaultWriteBackoff = 10 * time.Millisecond
+	// DefaultWriteBufferSize is the number of events kept with
+	// BufferOnWriteFailure, if WriteFailureOptions.BufferSize isn't set
+	DefaultWriteBufferSize = 1000
+)
+
+// WriteFailureOptions configures what happens when an event can't be written
+type WriteFailureOptions struct {
+	Policy WriteFailurePolicy
+	// Retries is the number of times a write is retried with RetryOnWriteFailure
+	Retries int
+	// Backoff is the delay before the first retry, which doubles for each
+	// subsequent retry
+	Backoff time.Duration
+	// BufferSize is the number of events kept with BufferOnWriteFailure, or
+	// queued with RetryOnWriteFailure, after which the oldest events are
+	// dropped
+	BufferSize int
+	// OnError is called with the error each time an event can't be written
+	// to either destination. It mustn't log.
+	OnError func(err error)
+}
+
+// WriteStats contains counters for events which couldn't be written
+type WriteStats struct {
+	// Failed is the number of events which couldn't be written to either
+	// destination, not including retries
+	Failed int64
+	// Retried is the number of times a write was retried
+	Retried int64
+	// Dropped is the number of events which were discarded
+	Dropped int64
+	// Buffered is the number of events waiting to be written
+	Buffered int64
+}
+
+// writeFailureOptions, writeBuffer, retrying and retryGeneration are
+// protected by destinationMutex
+var writeFailureOptions = WriteFailureOptions{Policy: ExitOnWriteFailure}
+var writeBuffer []*pendingLine
+var retrying bool
+var retryGeneration int
+
+var writesFailed, writesRetried, writesDropped, writesBuffered atomic.Int64
+
+// SetWriteFailurePolicy sets what happens when an event can't be written to
+// either the destination or the fallback destination
+func SetWriteFailurePolicy(opts WriteFailureOptions) {
+	if opts.Retries <= 0 {
+		opts.Retries = DefaultWriteRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultWriteBackoff
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultWriteBufferSize
+	}
+
+	destinationMutex.Lock()
+	defer destinationMutex.Unlock()
+	writeFailureOptions = opts
+
+	// stop any retries using the previous options
+	retryGeneration++
+	retrying = false
+
+	if opts.Policy != BufferOnWriteFailure && opts.Policy != RetryOnWriteFailure && len(writeBuffer) > 0 {
+		writesDropped.Add(int64(len(writeBuffer)))
+		writesBuffered.Store(0)
+		writeBuffer = nil
+	}
+
+	if opts.Policy == RetryOnWriteFailure && len(writeBuffer) > 0 {
+		startRetrying()
+	}
+}
+
+// GetWriteStats returns counters for events which couldn't be written
+func GetWriteStats() WriteStats {
+	return WriteStats{
+		Failed:   writesFailed.Load(),
+		Retried:  writesRetried.Load(),
+		Dropped:  writesDropped.Load(),
+		Buffered: writesBuffered.Load(),
+	}
+}
+
+// writeAll writes b to w, continuing after a short write until all of b
+// has been written or the writer returns an error. It returns the number of
+// bytes written.
+func writeAll(w io.Writer, b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := w.Write(b[written:])
+		if n < 0 || n > len(b)-written {
+			return written, errors.New("invalid write count")
+		}
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			return written, io.ErrShortWrite
+		}
+	}
+	return written, nil
+}
+
+// pendingLine is an event which is being written, along with how much of it
+// has been written to each destination, so that a retry doesn't write any
+// part of it to the same destination twice
+type pendingLine struct {
+	b               []byte
+	written         int
+	fallbackWritten int
+}
+
+// writeLine writes the rest of l to the destination, or the rest of it to
+// the fallback destination if that fails, so that the fallback destination
+// gets the whole line even if the destination wrote part of it.
+// destinationMutex must be held.
+func writeLine(l *pendingLine) error {
+	n, err := writeAll(destination, l.b[l.written:])
+	l.written += n
+	if err == nil {
+		return nil
+	}
+
+	fallbackDestinationMutex.Lock()
+	defer fallbackDestinationMutex.Unlock()
+	n, err = writeAll(fallbackDestination, l.b[l.fallbackWritten:])
+	l.fallbackWritten += n
+	return err
+}
+
+// handleWriteFailure applies the write failure policy to l, which couldn't
+// be written. destinationMutex must be held.
+func handleWriteFailure(l *pendingLine, err error) {
+	opts := writeFailureOptions
+
+	writesFailed.Add(1)
+	if opts.OnError != nil {
+		opts.OnError(err)
+	}
+
+	switch opts.Policy {
+	case RetryOnWriteFailure:
+		retryLater(l)
+	case DropOnWriteFailure:
+		writesDropped.Add(1)
+	case BufferOnWriteFailure:
+		bufferLine(l)
+	default:
+		// also defer an os.Exit since the panic might be captured in a recover
+		// block in the caller, but we always want to exit in this scenario
+		//
+		// Note: deferring an os.Exit makes this particular block untestable
+		// using conventional `go test`. But it's a narrow enough edge case that
+		// it probably isn't worth trying, and only occurs in extreme circumstances
+		// (os.Stdout and os.Stderr both being closed) where unpredictable
+		// behaviour is expected. It's not clear what a panic or os.Exit would do
+		// in this scenario, or if our process is even still alive to get this far.
+		defer os.Exit(1)
+		panic("error writing log data: " + err.Error())
+	}
+}
+
+// bufferLine adds l to writeBuffer, dropping the oldest event if it is full.
+// destinationMutex must be held.
+func bufferLine(l *pendingLine) {
+	if len(writeBuffer) >= writeFailureOptions.BufferSize {
+		writeBuffer = writeBuffer[1:]
+		writesDropped.Add(1)
+	}
+	writeBuffer = append(writeBuffer, l)
+	writesBuffered.Store(int64(len(writeBuffer)))
+}
+
+// writeBufferedLine writes the first event in writeBuffer, keeping it if
+// that fails. destinationMutex must be held.
+func writeBufferedLine() error {
+	if err := writeLine(writeBuffer[0]); err != nil {
+		return err
+	}
+
+	writeBuffer = writeBuffer[1:]
+	if len(writeBuffer) == 0 {
+		writeBuffer = nil
+	}
+	writesBuffered.Store(int64(len(writeBuffer)))
+	return nil
+}
+
+// flushWriteBuffer writes any events buffered by BufferOnWriteFailure,
+// stopping at the first failure. destinationMutex must be held.
+func flushWriteBuffer() error {
+	for len(writeBuffer) > 0 {
+		if err := writeBufferedLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryLater queues l to be retried by RetryOnWriteFailure, and starts
+// retrying if it isn't already. destinationMutex must be held.
+func retryLater(l *pendingLine) {
+	bufferLine(l)
+	if !retrying {
+		startRetrying()
+	}
+}
+
+// startRetrying retries the events in writeBuffer in the background.
+// destinationMutex must be held.
+func startRetrying() {
+	retrying = true
+	go retryWrites(retryGeneration)
+}
+
+// retryWrites writes the events in writeBuffer, retrying each one with
+// exponential backoff, and dropping it if it still can't be written. It runs
+// in the background, and doesn't hold destinationMutex while it waits, so
+// that logging isn't blocked. It stops if SetWriteFailurePolicy is called
+// while it waits.
+func retryWrites(generation int) {
+	destinationMutex.Lock()
+	defer destinationMutex.Unlock()
+
+	if generation != retryGeneration {
+		return
+	}
+
+	// the first event has already failed to be written
+	failed, retries := true, 0
+	for len(writeBuffer) > 0 {
+		opts := writeFailureOptions
+
+		if failed {
+			if retries >= opts.Retries {
+				writeBuffer = writeBuffer[1:]
+				writesBuffered.Store(int64(len(writeBuffer)))
+				writesDropped.Add(1)
+				failed, retries = false, 0
+				continue
+			}
+
+			destinationMutex.Unlock()
+			time.Sleep(opts.Backoff << retries)
+			destinationMutex.Lock()
+
+			if generation != retryGeneration {
+				return
+			}
+			// the events may have been dropped while waiting
+			if len(writeBuffer) == 0 {
+				break
+			}
+			retries++
+			writesRetried.Add(1)
+		}
+
+		if err := writeBufferedLine(); err != nil {
+			if !failed {
+				writesFailed.Add(1)
+				if opts.OnError != nil {
+					opts.OnError(err)
+				}
+				failed = true
+			}
+			continue
+		}
+		failed, retries = false, 0
+	}
+
+	if len(writeBuffer) == 0 {
+		writeBuffer = nil
+	}
+	retrying = false
+}
@@ -0,0 +1,242 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteFailurePolicy(t *testing.T) {
+	oldDestination := destination
+	oldFallbackDestination := fallbackDestination
+	defer func() {
+		destination = oldDestination
+		fallbackDestination = oldFallbackDestination
+		SetWriteFailurePolicy(WriteFailureOptions{Policy: ExitOnWriteFailure})
+	}()
+
+	// failing returns a writer which fails until *fail is false
+	failing := func(buf *bytes.Buffer, fail *bool) writer {
+		return writer{func(b []byte) (int, error) {
+			if *fail {
+				return 0, errors.New("write failed")
+			}
+			return buf.Write(b)
+		}}
+	}
+
+	Convey("Given the destination and fallback destination both fail", t, func() {
+		fail := true
+		buf := &bytes.Buffer{}
+		destination = failing(buf, &fail)
+		fallbackDestination = failing(&bytes.Buffer{}, &fail)
+
+		var callbackErrors []error
+		onError := func(err error) { callbackErrors = append(callbackErrors, err) }
+
+		before := GetWriteStats()
+
+		Convey("DropOnWriteFailure drops and counts the event", func() {
+			SetWriteFailurePolicy(WriteFailureOptions{Policy: DropOnWriteFailure, OnError: onError})
+
+			So(func() { printEvent([]byte("test")) }, ShouldNotPanic)
+
+			stats := GetWriteStats()
+			So(stats.Failed-before.Failed, ShouldEqual, 1)
+			So(stats.Dropped-before.Dropped, ShouldEqual, 1)
+			So(callbackErrors, ShouldHaveLength, 1)
+			So(callbackErrors[0].Error(), ShouldEqual, "write failed")
+		})
+
+		Convey("RetryOnWriteFailure retries with backoff in the background", func() {
+			SetWriteFailurePolicy(WriteFailureOptions{Policy: RetryOnWriteFailure, Retries: 3, Backoff: time.Millisecond, OnError: onError})
+
+			Convey("And writes the event if a retry succeeds", func() {
+				retries := 0
+				destination = writer{func(b []byte) (int, error) {
+					if retries < 2 {
+						retries++
+						return 0, errors.New("write failed")
+					}
+					return buf.Write(b)
+				}}
+
+				printEvent([]byte("test"))
+
+				So(waitFor(func() bool { return GetWriteStats().Buffered == 0 }), ShouldBeTrue)
+				destinationMutex.Lock()
+				defer destinationMutex.Unlock()
+				So(buf.String(), ShouldEqual, "test\n")
+				stats := GetWriteStats()
+				So(stats.Retried-before.Retried, ShouldEqual, 2)
+				So(stats.Dropped-before.Dropped, ShouldEqual, 0)
+			})
+
+			Convey("And drops the event if every retry fails", func() {
+				printEvent([]byte("test"))
+
+				So(waitFor(func() bool { return GetWriteStats().Dropped-before.Dropped == 1 }), ShouldBeTrue)
+				stats := GetWriteStats()
+				So(stats.Retried-before.Retried, ShouldEqual, 3)
+				So(stats.Buffered, ShouldEqual, 0)
+				So(callbackErrors, ShouldHaveLength, 1)
+			})
+
+			Convey("And doesn't block logging while it waits", func() {
+				SetWriteFailurePolicy(WriteFailureOptions{Policy: RetryOnWriteFailure, Retries: 1, Backoff: time.Hour})
+
+				start := time.Now()
+				printEvent([]byte("one"))
+				printEvent([]byte("two"))
+
+				So(time.Since(start), ShouldBeLessThan, time.Second)
+				So(GetWriteStats().Buffered, ShouldEqual, 2)
+				SetWriteFailurePolicy(WriteFailureOptions{Policy: DropOnWriteFailure})
+			})
+
+			Convey("And writes later events in order once a retry succeeds", func() {
+				SetWriteFailurePolicy(WriteFailureOptions{Policy: RetryOnWriteFailure, Retries: 3, Backoff: 20 * time.Millisecond})
+
+				printEvent([]byte("one"))
+				printEvent([]byte("two"))
+				destinationMutex.Lock()
+				fail = false
+				destinationMutex.Unlock()
+
+				So(waitFor(func() bool { return GetWriteStats().Buffered == 0 }), ShouldBeTrue)
+				destinationMutex.Lock()
+				defer destinationMutex.Unlock()
+				So(buf.String(), ShouldEqual, "one\ntwo\n")
+			})
+		})
+
+		Convey("BufferOnWriteFailure keeps the most recent events", func() {
+			SetWriteFailurePolicy(WriteFailureOptions{Policy: BufferOnWriteFailure, BufferSize: 2, OnError: onError})
+
+			printEvent([]byte("one"))
+			printEvent([]byte("two"))
+			printEvent([]byte("three"))
+
+			stats := GetWriteStats()
+			So(stats.Buffered, ShouldEqual, 2)
+			So(stats.Dropped-before.Dropped, ShouldEqual, 1)
+			So(callbackErrors, ShouldHaveLength, 3)
+
+			Convey("And writes them in order once a write succeeds", func() {
+				fail = false
+				printEvent([]byte("four"))
+
+				So(buf.String(), ShouldEqual, "two\nthree\nfour\n")
+				So(GetWriteStats().Buffered, ShouldEqual, 0)
+			})
+
+			Convey("And drops them if the policy is changed", func() {
+				SetWriteFailurePolicy(WriteFailureOptions{Policy: DropOnWriteFailure})
+
+				So(GetWriteStats().Buffered, ShouldEqual, 0)
+				So(GetWriteStats().Dropped-before.Dropped, ShouldEqual, 3)
+			})
+		})
+	})
+
+	Convey("Given a destination which makes short writes", t, func() {
+		SetWriteFailurePolicy(WriteFailureOptions{Policy: DropOnWriteFailure})
+		buf := &bytes.Buffer{}
+		fallbackCalled := false
+		destination = writer{func(b []byte) (int, error) {
+			return buf.Write(b[:1])
+		}}
+		fallbackDestination = writer{func(b []byte) (int, error) {
+			fallbackCalled = true
+			return len(b), nil
+		}}
+
+		Convey("The rest of the event is written to the destination", func() {
+			printEvent([]byte("test"))
+
+			So(buf.String(), ShouldEqual, "test\n")
+			So(fallbackCalled, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a destination which writes part of an event and then fails", t, func() {
+		buf := &bytes.Buffer{}
+		fallbackBuf := &bytes.Buffer{}
+		failures := 1
+		destination = writer{func(b []byte) (int, error) {
+			if failures > 0 {
+				failures--
+				n, _ := buf.Write(b[:2])
+				return n, errors.New("write failed")
+			}
+			return buf.Write(b)
+		}}
+
+		Convey("The whole event is written to the fallback destination", func() {
+			SetWriteFailurePolicy(WriteFailureOptions{Policy: DropOnWriteFailure})
+			fallbackDestination = fallbackBuf
+
+			printEvent([]byte("test"))
+
+			So(buf.String(), ShouldEqual, "te")
+			So(fallbackBuf.String(), ShouldEqual, "test\n")
+		})
+
+		Convey("A retry writes the rest of the event to each destination", func() {
+			SetWriteFailurePolicy(WriteFailureOptions{Policy: RetryOnWriteFailure, Backoff: time.Millisecond})
+			failures = 2
+			fallbackFailures := 1
+			fallbackDestination = writer{func(b []byte) (int, error) {
+				if fallbackFailures > 0 {
+					fallbackFailures--
+					n, _ := fallbackBuf.Write(b[:3])
+					return n, errors.New("write failed")
+				}
+				return fallbackBuf.Write(b)
+			}}
+
+			printEvent([]byte("test"))
+
+			So(waitFor(func() bool { return GetWriteStats().Buffered == 0 }), ShouldBeTrue)
+			destinationMutex.Lock()
+			defer destinationMutex.Unlock()
+			So(buf.String(), ShouldEqual, "test")
+			So(fallbackBuf.String(), ShouldEqual, "test\n")
+		})
+
+		Convey("A retry writes the rest of the event", func() {
+			SetWriteFailurePolicy(WriteFailureOptions{Policy: RetryOnWriteFailure, Backoff: time.Millisecond})
+			fallbackDestination = writer{func(b []byte) (int, error) {
+				return 0, errors.New("write failed")
+			}}
+
+			printEvent([]byte("test"))
+
+			So(waitFor(func() bool { return GetWriteStats().Buffered == 0 }), ShouldBeTrue)
+			destinationMutex.Lock()
+			defer destinationMutex.Unlock()
+			So(buf.String(), ShouldEqual, "test\n")
+		})
+	})
+
+	Convey("Given a destination which writes nothing without an error", t, func() {
+		SetWriteFailurePolicy(WriteFailureOptions{Policy: DropOnWriteFailure})
+		destination = writer{func(b []byte) (int, error) {
+			return 0, nil
+		}}
+		fallbackCalled := false
+		fallbackDestination = writer{func(b []byte) (int, error) {
+			fallbackCalled = true
+			return len(b), nil
+		}}
+
+		Convey("The event is written to the fallback destination", func() {
+			printEvent([]byte("test"))
+
+			So(fallbackCalled, ShouldBeTrue)
+		})
+	})
+}
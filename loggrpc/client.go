@@ -0,0 +1,150 @@
+package loggrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor returns a grpc.UnaryClientInterceptor which logs
+// unary calls made by a gRPC client.
+//
+// It is the gRPC equivalent of log.Transport. Each call will produce two log
+// entries - one when the call is sent, and another when the response is
+// received. The trace context and request ID are added to the outgoing
+// metadata, and the severity of the second event depends on the status code.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = injectOutgoing(ctx)
+		start := time.Now().UTC()
+
+		log.Info(ctx, "grpc request sent", log.GRPC(method, cc.Target(), "", 0, 0, &start, nil))
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		end := time.Now().UTC()
+		received := 0
+		if err == nil {
+			received = 1
+		}
+		logCompleted(ctx, "grpc response received", err, method, cc.Target(), received, 1, &start, &end)
+
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a grpc.StreamClientInterceptor which logs
+// streaming calls made by a gRPC client.
+//
+// The second event is logged when the stream ends, and includes the number of
+// messages sent and received. A stream ends when a call to RecvMsg returns an
+// error (including io.EOF for a successful call), when the response to a
+// client streaming call is received, or when the context is done, e.g. if
+// the caller abandons the stream and cancels the context.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = injectOutgoing(ctx)
+		start := time.Now().UTC()
+
+		log.Info(ctx, "grpc request sent", log.GRPC(method, cc.Target(), "", 0, 0, &start, nil))
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			end := time.Now().UTC()
+			logCompleted(ctx, "grpc response received", err, method, cc.Target(), 0, 0, &start, &end)
+			return nil, err
+		}
+
+		s := &clientStream{
+			ClientStream: cs,
+			desc:         desc,
+			ctx:          ctx,
+			method:       method,
+			target:       cc.Target(),
+			start:        start,
+			done:         make(chan struct{}),
+		}
+		go s.finishOnContextDone()
+
+		return s, nil
+	}
+}
+
+// clientStream wraps a grpc.ClientStream to count messages, and to log
+// an event when the stream ends
+type clientStream struct {
+	grpc.ClientStream
+	desc   *grpc.StreamDesc
+	ctx    context.Context
+	method string
+	target string
+	start  time.Time
+
+	mu       sync.Mutex
+	received int
+	sent     int
+	once     sync.Once
+	done     chan struct{}
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.sent++
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.received++
+		s.mu.Unlock()
+
+		// a call without server streaming, e.g. a client streaming call
+		// using CloseAndRecv, has a single response, and grpc checks for
+		// the end of the stream without calling this wrapper
+		if !s.desc.ServerStreams {
+			s.finish(nil)
+		}
+		return nil
+	}
+
+	s.finish(err)
+	return err
+}
+
+// finishOnContextDone logs the completed event if the context is done before
+// the stream ends, in the same way as grpc's stats handlers
+func (s *clientStream) finishOnContextDone() {
+	select {
+	case <-s.ctx.Done():
+		s.finish(status.FromContextError(s.ctx.Err()).Err())
+	case <-s.done:
+	}
+}
+
+// finish logs the completed event the first time it is called
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		defer close(s.done)
+
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+
+		end := time.Now().UTC()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		logCompleted(s.ctx, "grpc response received", err, s.method, s.target, s.received, s.sent, &s.start, &end)
+	})
+}
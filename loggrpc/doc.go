@@ -0,0 +1,5 @@
+// Package loggrpc provides gRPC interceptors which log calls using the log package.
+//
+// It is a separate module so that services which don't use gRPC don't need
+// to depend on it.
+package loggrpc
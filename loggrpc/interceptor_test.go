@@ -0,0 +1,299 @@
+package loggrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// syncBuffer is a bytes.Buffer which is safe to write to from
+// multiple goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// events returns the logged events, skipping any which aren't gRPC events
+func (b *syncBuffer) events() []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err == nil && e["grpc"] != nil {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// uploadDesc describes a client streaming method, which the health service
+// doesn't have. It counts the requests it receives and responds once.
+var uploadDesc = grpc.StreamDesc{
+	StreamName:    "Upload",
+	ClientStreams: true,
+	Handler: func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			var req healthpb.HealthCheckRequest
+			err := stream.RecvMsg(&req)
+			if errors.Is(err, io.EOF) {
+				return stream.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	},
+}
+
+func startServer(t *testing.T) (*grpc.ClientConn, *health.Server) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor()),
+	)
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Upload",
+		HandlerType: (*interface{})(nil),
+		Streams:     []grpc.StreamDesc{uploadDesc},
+	}, struct{}{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, hs
+}
+
+func TestInterceptors(t *testing.T) {
+	buf := &syncBuffer{}
+	log.SetDestination(buf, nil)
+
+	oldPropagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(oldPropagator)
+
+	conn, hs := startServer(t)
+	client := healthpb.NewHealthClient(conn)
+
+	Convey("Given a client and server using the interceptors", t, func() {
+		buf.buf.Reset()
+
+		traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+		spanID, _ := trace.SpanIDFromHex("0102030405060708")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+
+		Convey("When a successful unary call is made", func() {
+			_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+			So(err, ShouldBeNil)
+
+			events := buf.events()
+			So(events, ShouldHaveLength, 4)
+
+			Convey("Then the client and server events are logged in order", func() {
+				So(events[0]["event"], ShouldEqual, "grpc request sent")
+				So(events[1]["event"], ShouldEqual, "grpc request received")
+				So(events[2]["event"], ShouldEqual, "grpc request completed")
+				So(events[3]["event"], ShouldEqual, "grpc response received")
+			})
+
+			Convey("Then the completed event includes the call data", func() {
+				g := events[2]["grpc"].(map[string]interface{})
+				So(g["service"], ShouldEqual, "grpc.health.v1.Health")
+				So(g["method"], ShouldEqual, "Check")
+				So(g["code"], ShouldEqual, "OK")
+				So(g["peer"], ShouldNotBeEmpty)
+				So(g["messages_received"], ShouldEqual, 1)
+				So(g["messages_sent"], ShouldEqual, 1)
+				So(g["duration"], ShouldNotBeNil)
+				So(events[2]["severity"], ShouldEqual, 3)
+				So(events[2]["errors"], ShouldBeNil)
+			})
+
+			Convey("Then the trace context is propagated to the server", func() {
+				for _, e := range events {
+					So(e["trace_id"], ShouldEqual, traceID.String())
+				}
+			})
+		})
+
+		Convey("When a unary call fails", func() {
+			_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+			So(status.Code(err), ShouldEqual, codes.NotFound)
+
+			events := buf.events()
+			So(events, ShouldHaveLength, 4)
+
+			Convey("Then the status code and error are logged", func() {
+				g := events[2]["grpc"].(map[string]interface{})
+				So(g["code"], ShouldEqual, "NotFound")
+				So(g["messages_sent"], ShouldBeNil)
+				So(events[2]["errors"], ShouldNotBeNil)
+				So(events[3]["errors"], ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a streaming call is made", func() {
+			hs.SetServingStatus("stream", healthpb.HealthCheckResponse_SERVING)
+
+			streamCtx, cancel := context.WithCancel(ctx)
+			stream, err := client.Watch(streamCtx, &healthpb.HealthCheckRequest{Service: "stream"})
+			So(err, ShouldBeNil)
+
+			_, err = stream.Recv()
+			So(err, ShouldBeNil)
+			hs.SetServingStatus("stream", healthpb.HealthCheckResponse_NOT_SERVING)
+			_, err = stream.Recv()
+			So(err, ShouldBeNil)
+
+			cancel()
+			_, err = stream.Recv()
+			So(status.Code(err), ShouldEqual, codes.Canceled)
+
+			Convey("Then the client event includes the message counts", func() {
+				var received map[string]interface{}
+				for _, e := range buf.events() {
+					if e["event"] == "grpc response received" {
+						received = e
+					}
+				}
+				So(received, ShouldNotBeNil)
+				g := received["grpc"].(map[string]interface{})
+				So(g["method"], ShouldEqual, "Watch")
+				So(g["code"], ShouldEqual, "Canceled")
+				So(g["messages_received"], ShouldEqual, 2)
+				So(g["messages_sent"], ShouldEqual, 1)
+				So(received["severity"], ShouldEqual, 3)
+			})
+		})
+	})
+}
+
+// clientEvent returns the last "grpc response received" event
+func clientEvent(buf *syncBuffer) map[string]interface{} {
+	var received map[string]interface{}
+	for _, e := range buf.events() {
+		if e["event"] == "grpc response received" {
+			received = e
+		}
+	}
+	return received
+}
+
+func TestClientStreamInterceptor(t *testing.T) {
+	buf := &syncBuffer{}
+	log.SetDestination(buf, nil)
+
+	conn, _ := startServer(t)
+
+	Convey("Given a client using the stream interceptor", t, func() {
+		buf.buf.Reset()
+
+		Convey("When a client streaming call is made", func() {
+			stream, err := conn.NewStream(context.Background(), &uploadDesc, "/test.Upload/Upload")
+			So(err, ShouldBeNil)
+
+			for i := 0; i < 3; i++ {
+				So(stream.SendMsg(&healthpb.HealthCheckRequest{}), ShouldBeNil)
+			}
+			// this is what CloseAndRecv does in generated code
+			So(stream.CloseSend(), ShouldBeNil)
+			So(stream.RecvMsg(&healthpb.HealthCheckResponse{}), ShouldBeNil)
+
+			Convey("Then the response is logged after it is received", func() {
+				received := clientEvent(buf)
+				So(received, ShouldNotBeNil)
+				g := received["grpc"].(map[string]interface{})
+				So(g["method"], ShouldEqual, "Upload")
+				So(g["code"], ShouldEqual, "OK")
+				So(g["messages_sent"], ShouldEqual, 3)
+				So(g["messages_received"], ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a stream is abandoned and its context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			_, err := conn.NewStream(ctx, &uploadDesc, "/test.Upload/Upload")
+			So(err, ShouldBeNil)
+			cancel()
+
+			Convey("Then the stream is logged as cancelled", func() {
+				var received map[string]interface{}
+				for i := 0; i < 500 && received == nil; i++ {
+					time.Sleep(time.Millisecond)
+					received = clientEvent(buf)
+				}
+				So(received, ShouldNotBeNil)
+				So(received["grpc"].(map[string]interface{})["code"], ShouldEqual, "Canceled")
+			})
+		})
+	})
+}
+
+func TestLogCompleted(t *testing.T) {
+	buf := &syncBuffer{}
+	log.SetDestination(buf, nil)
+
+	Convey("logCompleted maps status codes to severities", t, func() {
+		for code, severity := range map[codes.Code]float64{
+			codes.OK:                 3,
+			codes.NotFound:           3,
+			codes.DeadlineExceeded:   2,
+			codes.PermissionDenied:   2,
+			codes.Internal:           1,
+			codes.Unavailable:        1,
+			codes.Code(1000):         1,
+			codes.Unauthenticated:    3,
+			codes.FailedPrecondition: 2,
+		} {
+			buf.buf.Reset()
+			logCompleted(context.Background(), "test", status.Error(code, "error"), "/svc/method", "", 0, 0, nil, nil)
+
+			events := buf.events()
+			So(events, ShouldHaveLength, 1)
+			So(events[0]["severity"], ShouldEqual, severity)
+		}
+	})
+}
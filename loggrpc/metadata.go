@@ -0,0 +1,68 @@
+package loggrpc
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDMetadataKey is the metadata equivalent of the X-Request-Id header
+const requestIDMetadataKey = "x-request-id"
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// extractIncoming returns a context including the trace context and request ID
+// from the incoming metadata of ctx
+func extractIncoming(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	if requestID := metadataCarrier(md).Get(requestIDMetadataKey); requestID != "" && request.GetRequestId(ctx) == "" {
+		ctx = request.WithRequestId(ctx, requestID)
+	}
+
+	return ctx
+}
+
+// injectOutgoing returns a context with the trace context and request ID
+// from ctx added to its outgoing metadata
+func injectOutgoing(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	if requestID := request.GetRequestId(ctx); requestID != "" && len(md.Get(requestIDMetadataKey)) == 0 {
+		md.Set(requestIDMetadataKey, requestID)
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -0,0 +1,98 @@
+package loggrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor which logs
+// unary calls received by a gRPC server.
+//
+// It is the gRPC equivalent of log.Middleware. Each call will produce two log
+// entries - one when the call is received, and another when it has completed.
+// The trace context and request ID are extracted from the incoming metadata,
+// and the severity of the completed event depends on the status code.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = extractIncoming(ctx)
+		peerAddr := peerAddress(ctx)
+		start := time.Now().UTC()
+
+		log.Info(ctx, "grpc request received", log.GRPC(info.FullMethod, peerAddr, "", 0, 0, &start, nil))
+
+		resp, err := handler(ctx, req)
+
+		end := time.Now().UTC()
+		sent := 0
+		if err == nil {
+			sent = 1
+		}
+		logCompleted(ctx, "grpc request completed", err, info.FullMethod, peerAddr, 1, sent, &start, &end)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor which logs
+// streaming calls received by a gRPC server.
+//
+// It logs the same events as UnaryServerInterceptor, and the completed event
+// includes the number of messages received from and sent to the client.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := extractIncoming(ss.Context())
+		peerAddr := peerAddress(ctx)
+		start := time.Now().UTC()
+
+		log.Info(ctx, "grpc request received", log.GRPC(info.FullMethod, peerAddr, "", 0, 0, &start, nil))
+
+		ws := &serverStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, ws)
+
+		end := time.Now().UTC()
+		logCompleted(ctx, "grpc request completed", err, info.FullMethod, peerAddr, ws.received, ws.sent, &start, &end)
+
+		return err
+	}
+}
+
+// serverStream wraps a grpc.ServerStream to count messages, and to pass the
+// context including the extracted trace context to the handler
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+	sent     int
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+	}
+	return err
+}
+
+// peerAddress returns the address of the peer in ctx, if there is one
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package loggrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// logCompleted logs the event for a completed call, with a severity
+// which depends on the status code of err:
+//
+//   - OK, Canceled, InvalidArgument, NotFound, AlreadyExists and
+//     Unauthenticated are expected results or client errors, and are INFO
+//   - DeadlineExceeded, PermissionDenied, ResourceExhausted,
+//     FailedPrecondition, Aborted and OutOfRange are WARN
+//   - Unknown, Unimplemented, Internal, Unavailable, DataLoss and any
+//     other codes are ERROR
+//
+// The error is included in the event for any status other than OK.
+func logCompleted(ctx context.Context, event string, err error, fullMethod, peerAddr string, received, sent int, start, end *time.Time) {
+	code := status.Code(err)
+
+	severity := log.ERROR
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		severity = log.INFO
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		severity = log.WARN
+	}
+
+	grpcEvent := log.GRPC(fullMethod, peerAddr, code.String(), received, sent, start, end)
+	if err == nil {
+		log.Event(ctx, event, severity, grpcEvent)
+		return
+	}
+
+	log.Event(ctx, event, severity, grpcEvent, log.FormatErrors([]error{err}))
+}
@@ -0,0 +1,121 @@
+package logzap
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"go.uber.org/zap/zapcore"
+)
+
+// traceIDKeys are the field names which are used as the trace ID of an
+// event if there is no context field
+var traceIDKeys = []string{"trace_id", "request_id", "request-id"}
+
+// NewCore returns a zapcore.Core which logs entries as events using the log
+// package. Entries at levels not enabled by enab are discarded, and the
+// minimum severity of the log package is also applied.
+//
+// The entry message is used as the event name, and fields are included as
+// Data. Levels are mapped to severities, so DebugLevel is DEBUG, InfoLevel is
+// INFO, WarnLevel is WARN, ErrorLevel is ERROR, and DPanicLevel and above are
+// FATAL. Error fields are included as errors, and the logger name, caller and
+// stack trace are included as the "logger", "caller" and "stack" fields of
+// Data.
+//
+// A context.Context field (e.g. zap.Any("ctx", ctx)) is used as the event
+// context, so the trace ID and other context data are included. Otherwise,
+// a "trace_id" or "request_id" string field is used as the trace ID.
+//
+//	logger := zap.New(logzap.NewCore(zapcore.DebugLevel))
+func NewCore(enab zapcore.LevelEnabler) zapcore.Core {
+	return &core{LevelEnabler: enab}
+}
+
+type core struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	f := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	f = append(f, c.fields...)
+	f = append(f, fields...)
+	return &core{LevelEnabler: c.LevelEnabler, fields: f}
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ctx := context.Background()
+	var errs []error
+	enc := zapcore.NewMapObjectEncoder()
+
+	for _, fs := range [][]zapcore.Field{c.fields, fields} {
+		for _, f := range fs {
+			if f.Type == zapcore.ErrorType {
+				if err, ok := f.Interface.(error); ok && err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			if fctx, ok := f.Interface.(context.Context); ok {
+				ctx = fctx
+				continue
+			}
+			f.AddTo(enc)
+		}
+	}
+
+	data := log.Data(enc.Fields)
+	if ent.LoggerName != "" {
+		data["logger"] = ent.LoggerName
+	}
+	if ent.Caller.Defined {
+		data["caller"] = ent.Caller.TrimmedPath()
+	}
+	if ent.Stack != "" {
+		data["stack"] = ent.Stack
+	}
+
+	if request.GetRequestId(ctx) == "" {
+		for _, k := range traceIDKeys {
+			if id, ok := data[k].(string); ok && id != "" {
+				ctx = request.WithRequestId(ctx, id)
+				delete(data, k)
+				break
+			}
+		}
+	}
+
+	severity := log.INFO
+	switch {
+	case ent.Level >= zapcore.DPanicLevel:
+		severity = log.FATAL
+	case ent.Level == zapcore.ErrorLevel:
+		severity = log.ERROR
+	case ent.Level == zapcore.WarnLevel:
+		severity = log.WARN
+	case ent.Level <= zapcore.DebugLevel:
+		severity = log.DEBUG
+	}
+
+	if len(errs) == 0 {
+		log.Event(ctx, ent.Message, severity, data)
+		return nil
+	}
+
+	log.Event(ctx, ent.Message, severity, data, log.FormatErrors(errs))
+	return nil
+}
+
+// Sync does nothing, because events are written by the log package as
+// soon as they are logged
+func (c *core) Sync() error {
+	return nil
+}
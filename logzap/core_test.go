@@ -0,0 +1,109 @@
+package logzap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func lastEvent(buf *bytes.Buffer) map[string]interface{} {
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var e map[string]interface{}
+	if err := json.Unmarshal(lines[len(lines)-1], &e); err != nil {
+		return nil
+	}
+	return e
+}
+
+func TestCore(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetDestination(buf, nil)
+	log.Namespace = "logzap-test"
+
+	Convey("Given a zap.Logger using the core", t, func() {
+		buf.Reset()
+		logger := zap.New(NewCore(zapcore.DebugLevel), zap.AddCaller()).Named("legacy")
+
+		Convey("Entries are logged as events with fields as data", func() {
+			logger.Info("connected to database", zap.String("host", "db"), zap.Int("port", 5432))
+
+			e := lastEvent(buf)
+			So(e["event"], ShouldEqual, "connected to database")
+			So(e["namespace"], ShouldEqual, "logzap-test")
+			So(e["severity"], ShouldEqual, 3)
+			data := e["data"].(map[string]interface{})
+			So(data["host"], ShouldEqual, "db")
+			So(data["port"], ShouldEqual, 5432)
+			So(data["logger"], ShouldEqual, "legacy")
+			So(data["caller"], ShouldStartWith, "logzap/core_test.go:")
+		})
+
+		Convey("Levels are mapped to severities", func() {
+			for _, tc := range []struct {
+				f        func(string, ...zap.Field)
+				severity float64
+			}{
+				{logger.Warn, 2},
+				{logger.Error, 1},
+				{logger.DPanic, 0},
+			} {
+				tc.f("event")
+				So(lastEvent(buf)["severity"], ShouldEqual, tc.severity)
+			}
+		})
+
+		Convey("Error fields are logged as errors", func() {
+			logger.Error("query failed", zap.Error(errors.New("connection reset")))
+
+			e := lastEvent(buf)
+			So(e["severity"], ShouldEqual, 1)
+			errs := e["errors"].([]interface{})
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].(map[string]interface{})["message"], ShouldEqual, "connection reset")
+		})
+
+		Convey("A context field is used as the event context", func() {
+			ctx := request.WithRequestId(context.Background(), "ctx-trace")
+			logger.Info("event", zap.Any("ctx", ctx))
+			So(lastEvent(buf)["trace_id"], ShouldEqual, "ctx-trace")
+		})
+
+		Convey("A trace_id field is used as the trace ID", func() {
+			logger.With(zap.String("trace_id", "field-trace")).Info("event")
+
+			e := lastEvent(buf)
+			So(e["trace_id"], ShouldEqual, "field-trace")
+			So(e["data"].(map[string]interface{})["trace_id"], ShouldBeNil)
+		})
+
+		Convey("Fields added with With are included", func() {
+			child := logger.With(zap.String("component", "cache"))
+			child.Info("event", zap.Bool("hit", true))
+
+			data := lastEvent(buf)["data"].(map[string]interface{})
+			So(data["component"], ShouldEqual, "cache")
+			So(data["hit"], ShouldBeTrue)
+
+			Convey("And not to the parent logger", func() {
+				logger.Info("event")
+				So(lastEvent(buf)["data"].(map[string]interface{})["component"], ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Entries at levels which aren't enabled are discarded", t, func() {
+		buf.Reset()
+		logger := zap.New(NewCore(zapcore.InfoLevel))
+		logger.Debug("debug")
+		So(buf.Len(), ShouldEqual, 0)
+		So(logger.Sync(), ShouldBeNil)
+	})
+}
@@ -0,0 +1,7 @@
+// Package logzap provides a zapcore.Core which logs zap entries as events
+// using the log package, so that services and dependencies using zap produce
+// the same log schema.
+//
+// It is a separate module so that services which don't use zap don't need
+// to depend on it.
+package logzap
@@ -0,0 +1,31 @@
+module github.com/ONSdigital/log.go/logzap
+
+go 1.24
+
+require (
+	github.com/ONSdigital/dp-net/v3 v3.2.0
+	github.com/ONSdigital/log.go/v2 v2.5.0
+	github.com/smartystreets/goconvey v1.8.1
+	go.uber.org/zap v1.27.0
+)
+
+require (
+	github.com/ONSdigital/dp-api-clients-go/v2 v2.266.0 // indirect
+	github.com/fatih/color v1.18.0 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/gopherjs/gopherjs v1.17.2 // indirect
+	github.com/hokaccha/go-prettyjson v0.0.0-20211117102719-0474bc63780f // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/mattn/go-colorable v0.1.14 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/smarty/assertions v1.16.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel v1.35.0 // indirect
+	go.opentelemetry.io/otel/metric v1.35.0 // indirect
+	go.opentelemetry.io/otel/trace v1.35.0 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+)
+
+replace github.com/ONSdigital/log.go/v2 => ../
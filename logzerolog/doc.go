@@ -0,0 +1,7 @@
+// Package logzerolog provides a zerolog writer which logs zerolog events as
+// events using the log package, so that services and dependencies using
+// zerolog produce the same log schema.
+//
+// It is a separate module so that services which don't use zerolog don't
+// need to depend on it.
+package logzerolog
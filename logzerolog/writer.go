@@ -0,0 +1,119 @@
+package logzerolog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceIDKeys are the field names which are used as the trace ID of an event
+var traceIDKeys = []string{"trace_id", "request_id", "request-id"}
+
+// NewWriter returns a zerolog.LevelWriter which logs each zerolog event as
+// an event using the log package, for example:
+//
+//	logger := zerolog.New(logzerolog.NewWriter()).Hook(logzerolog.TraceHook{})
+//
+// The message is used as the event name, and fields are included as Data.
+// Levels are mapped to severities, so TraceLevel is TRACE, DebugLevel is
+// DEBUG, InfoLevel (and events without a level) is INFO, WarnLevel is WARN,
+// ErrorLevel is ERROR, and FatalLevel and PanicLevel are FATAL. The error
+// field is included as an error, and a "trace_id" or "request_id" field is
+// used as the trace ID. The zerolog timestamp is not included, since each
+// event has its own created_at time.
+func NewWriter() zerolog.LevelWriter {
+	return writer{}
+}
+
+type writer struct{}
+
+func (w writer) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w writer) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+
+	var data log.Data
+	if err := dec.Decode(&data); err != nil {
+		return 0, err
+	}
+
+	if l, ok := data[zerolog.LevelFieldName].(string); ok {
+		if parsed, err := zerolog.ParseLevel(l); err == nil {
+			level = parsed
+		}
+	}
+
+	event, _ := data[zerolog.MessageFieldName].(string)
+
+	var errs []error
+	if e, ok := data[zerolog.ErrorFieldName].(string); ok {
+		errs = append(errs, errors.New(e))
+	}
+
+	ctx := context.Background()
+	for _, k := range traceIDKeys {
+		if id, ok := data[k].(string); ok && id != "" {
+			ctx = request.WithRequestId(ctx, id)
+			delete(data, k)
+			break
+		}
+	}
+
+	for _, k := range []string{zerolog.LevelFieldName, zerolog.MessageFieldName, zerolog.ErrorFieldName, zerolog.TimestampFieldName} {
+		delete(data, k)
+	}
+
+	severity := log.INFO
+	switch level {
+	case zerolog.FatalLevel, zerolog.PanicLevel:
+		severity = log.FATAL
+	case zerolog.ErrorLevel:
+		severity = log.ERROR
+	case zerolog.WarnLevel:
+		severity = log.WARN
+	case zerolog.DebugLevel:
+		severity = log.DEBUG
+	case zerolog.TraceLevel:
+		severity = log.TRACE
+	}
+
+	if len(errs) == 0 {
+		log.Event(ctx, event, severity, data)
+	} else {
+		log.Event(ctx, event, severity, data, log.FormatErrors(errs))
+	}
+
+	return len(p), nil
+}
+
+// TraceHook is a zerolog.Hook which adds a "trace_id" field to events logged
+// with a context (using zerolog.Event.Ctx), so that NewWriter can include the
+// trace ID. The OpenTelemetry trace ID is used if there is one, otherwise the
+// request ID.
+type TraceHook struct{}
+
+// Run implements zerolog.Hook
+func (TraceHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	ctx := e.GetCtx()
+	if ctx == nil {
+		return
+	}
+
+	if traceID := trace.SpanFromContext(ctx).SpanContext().TraceID(); traceID.IsValid() {
+		e.Str("trace_id", traceID.String())
+		return
+	}
+
+	if requestID := request.GetRequestId(ctx); requestID != "" {
+		e.Str("trace_id", requestID)
+	}
+}
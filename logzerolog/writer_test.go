@@ -0,0 +1,88 @@
+package logzerolog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/rs/zerolog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func lastEvent(buf *bytes.Buffer) map[string]interface{} {
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var e map[string]interface{}
+	if err := json.Unmarshal(lines[len(lines)-1], &e); err != nil {
+		return nil
+	}
+	return e
+}
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetDestination(buf, nil)
+	log.SetMinimumSeverity(log.TRACE)
+	defer log.SetMinimumSeverity(log.INFO)
+	log.Namespace = "logzerolog-test"
+
+	Convey("Given a zerolog.Logger using the writer", t, func() {
+		buf.Reset()
+		logger := zerolog.New(NewWriter()).With().Timestamp().Str("component", "cache").Logger().Hook(TraceHook{})
+
+		Convey("Events are logged with fields as data", func() {
+			logger.Info().Int("port", 5432).Msg("connected to database")
+
+			e := lastEvent(buf)
+			So(e["event"], ShouldEqual, "connected to database")
+			So(e["namespace"], ShouldEqual, "logzerolog-test")
+			So(e["severity"], ShouldEqual, 3)
+			data := e["data"].(map[string]interface{})
+			So(data["port"], ShouldEqual, 5432)
+			So(data["component"], ShouldEqual, "cache")
+			So(data["time"], ShouldBeNil)
+			So(data["level"], ShouldBeNil)
+			So(data["message"], ShouldBeNil)
+		})
+
+		Convey("Levels are mapped to severities", func() {
+			for level, severity := range map[zerolog.Level]float64{
+				zerolog.TraceLevel: 5,
+				zerolog.DebugLevel: 4,
+				zerolog.WarnLevel:  2,
+				zerolog.ErrorLevel: 1,
+				zerolog.NoLevel:    3,
+			} {
+				logger.WithLevel(level).Msg("event")
+				So(lastEvent(buf)["severity"], ShouldEqual, severity)
+			}
+		})
+
+		Convey("The error field is logged as an error", func() {
+			logger.Error().Err(errors.New("connection reset")).Msg("query failed")
+
+			e := lastEvent(buf)
+			errs := e["errors"].([]interface{})
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].(map[string]interface{})["message"], ShouldEqual, "connection reset")
+			So(e["data"].(map[string]interface{})["error"], ShouldBeNil)
+		})
+
+		Convey("The trace ID is taken from the event context", func() {
+			ctx := request.WithRequestId(context.Background(), "ctx-trace")
+			logger.Info().Ctx(ctx).Msg("event")
+
+			e := lastEvent(buf)
+			So(e["trace_id"], ShouldEqual, "ctx-trace")
+			So(e["data"].(map[string]interface{})["trace_id"], ShouldBeNil)
+		})
+	})
+
+	Convey("Invalid JSON returns an error", t, func() {
+		_, err := NewWriter().Write([]byte("not json"))
+		So(err, ShouldNotBeNil)
+	})
+}